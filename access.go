@@ -0,0 +1,111 @@
+package rotatelog
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clfTime is the time layout of the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+var clfEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// AccessLog writes HTTP access log lines in the Combined Log Format to a
+// rotated file, raw as Writer does, without levels.
+type AccessLog struct {
+	*Writer
+}
+
+// NewAccessLog opens path as NewWriter does.
+func NewAccessLog(path string, rc *RotateConfig) (*AccessLog, error) {
+	w, err := NewWriter(path, rc)
+	if nil != err {
+		return nil, err
+	}
+	return &AccessLog{w}, nil
+}
+
+// Log writes the line of r answered at t with status and size body bytes.
+func (a *AccessLog) Log(r *http.Request, t time.Time, status int, size int64) error {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if nil != err {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	} else if r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	}
+	sent := "-"
+	if size > 0 {
+		sent = strconv.FormatInt(size, 10)
+	}
+
+	b := make([]byte, 0, 256)
+	b = append(b, orDash(host)...)
+	b = append(b, " - "...)
+	b = append(b, user...)
+	b = append(b, " ["...)
+	b = t.AppendFormat(b, clfTime)
+	b = append(b, "] "...)
+	b = appendQuoted(b, r.Method+" "+r.RequestURI+" "+r.Proto)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(status), 10)
+	b = append(b, ' ')
+	b = append(b, sent...)
+	b = append(b, ' ')
+	b = appendQuoted(b, orDash(r.Referer()))
+	b = append(b, ' ')
+	b = appendQuoted(b, orDash(r.UserAgent()))
+	_, err = a.Write(append(b, '\n'))
+	return err
+}
+
+// Handler logs each request served by next.
+func (a *AccessLog) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			start = time.Now()
+			rec   = &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		)
+		next.ServeHTTP(rec, r)
+		a.Log(r, start, rec.status, rec.size)
+	})
+}
+
+// statusRecorder keeps the status and body size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// appendQuoted appends s in double quotes, escaping quotes and
+// backslashes as Apache does.
+func appendQuoted(b []byte, s string) []byte {
+	b = append(b, '"')
+	b = append(b, clfEscaper.Replace(s)...)
+	return append(b, '"')
+}
@@ -0,0 +1,53 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	a, err := NewAccessLog(logFile, &RotateConfig{Duration: time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	r := httptest.NewRequest("GET", "/search?q=a", nil)
+	r.RemoteAddr = "192.0.2.7:51234"
+	r.SetBasicAuth("frank", "secret")
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", `curl/8.0 "test"`)
+	at := time.Date(2026, 10, 16, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+	if err = a.Log(r, at, 200, 2326); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	r = httptest.NewRequest("POST", "/missing", nil)
+	r.RemoteAddr = "192.0.2.8:1"
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 {
+		t.Fatalf("archives %v", archives)
+	}
+	want := `192.0.2.7 - frank [16/Oct/2026:13:55:36 -0700] "GET /search?q=a HTTP/1.1" 200 2326 "http://example.com/" "curl/8.0 \"test\""` + "\n"
+	if b, _ := ioutil.ReadFile(archives[0]); string(b) != want {
+		t.Errorf("archive has %q, want %q", b, want)
+	}
+	b, _ := ioutil.ReadFile(logFile)
+	if re := regexp.MustCompile(`^192\.0\.2\.8 - - \[[^]]+\] "POST /missing HTTP/1\.1" 404 19 "-" "-"\n$`); !re.Match(b) {
+		t.Errorf("log file has %q", b)
+	}
+}
@@ -0,0 +1,156 @@
+package rotatelog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchiveInfo describes a rotated log file.
+type ArchiveInfo struct {
+	Path       string
+	Time       time.Time // parsed from the file name suffix
+	Size       int64
+	Compressed bool
+	Encrypted  bool
+	Content    string // the file holding a ContentAddressed archive, Path is its name
+}
+
+// RetentionPolicy chooses which archives cleanup removes after a rotation.
+// Select gets the archives newest first and returns the paths to delete.
+type RetentionPolicy interface {
+	Select(archives []ArchiveInfo, now time.Time) (delete []string)
+}
+
+// AgePolicy, the default RetentionPolicy, removes archives older than it.
+type AgePolicy time.Duration
+
+func (p AgePolicy) Select(archives []ArchiveInfo, now time.Time) (delete []string) {
+	for _, a := range archives {
+		if now.Sub(a.Time) > time.Duration(p) {
+			delete = append(delete, a.Path)
+		}
+	}
+	return
+}
+
+// Archives lists the rotated files of the current log file, newest first.
+// It returns nothing unless the output is a file.
+func (l *Logger) Archives() ([]ArchiveInfo, error) {
+	fileName := l.path()
+	if fileName == "" || l.rotateCfg == nil {
+		return nil, nil
+	}
+	return l.scanArchives(fileName)
+}
+
+// scanArchives finds the rotated files of fileName, named after its base
+// with the timestamp suffix and optional fragment or counter, .gz and .enc
+// extensions, newest first.
+func (l *Logger) scanArchives(fileName string) (archives []ArchiveInfo, err error) {
+	dir := filepath.Dir(fileName)
+	files, err := filepath.Glob(fmt.Sprintf("%s/*", dir))
+	if nil != err {
+		l.Error("fail in Glob dir:%s, err:%s", dir, err.Error())
+		return
+	}
+
+	var (
+		rx      *regexp.Regexp
+		base    = strings.TrimSuffix(filepath.Base(fileName), ".gz") // LiveGzip keeps .gz last
+		ext     = regexp.QuoteMeta(l.rotateCfg.compressExt())
+		pattern = fmt.Sprintf(`^%s\.([0-9,]{%d})(\.[0-9]+)?(\.gz|\.%s)?(\.enc)?$`, regexp.QuoteMeta(base), len(l.rotateCfg.suffixFormat()), ext)
+	)
+
+	rx, err = regexp.Compile(pattern)
+	if nil != err {
+		l.Error("Failed to compile pattern. pattern:%s, err:%s", pattern, err.Error())
+		return
+	}
+
+	var index map[string]string
+	if l.rotateCfg.ContentAddressed {
+		index = readIndex(dir)
+		for name := range index {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+
+	seq := map[string]int{}
+	for _, fn := range files {
+		var match = rx.FindStringSubmatch(filepath.Base(fn))
+		if match == nil || fn == fileName {
+			continue
+		}
+		wt, err := time.ParseInLocation(l.rotateCfg.suffixFormat(), match[1], time.Local)
+		if nil != err {
+			l.Error("parse time err. time-str:%s, err:%s", match[1], err.Error())
+			continue
+		}
+		stat, content := fn, ""
+		if name, ok := index[filepath.Base(fn)]; ok {
+			content = filepath.Join(dir, name)
+			stat = content
+		}
+		fi, err := os.Stat(stat)
+		if nil != err || fi.IsDir() {
+			continue
+		}
+		seq[fn], _ = strconv.Atoi(strings.TrimPrefix(match[2], "."))
+		archives = append(archives, ArchiveInfo{
+			Path:       fn,
+			Time:       wt,
+			Size:       fi.Size(),
+			Compressed: match[3] != "",
+			Encrypted:  match[4] != "",
+			Content:    content,
+		})
+	}
+
+	// within a period a higher collision counter is newer
+	sort.Slice(archives, func(i, j int) bool {
+		if !archives[i].Time.Equal(archives[j].Time) {
+			return archives[i].Time.After(archives[j].Time)
+		}
+		if si, sj := seq[archives[i].Path], seq[archives[j].Path]; si != sj {
+			return si > sj
+		}
+		return archives[i].Path > archives[j].Path
+	})
+	return
+}
+
+// checkDiskUsage logs a Warning when the archives take more than
+// UsageWarnBytes, once per UsageWarnInterval.
+func (l *Logger) checkDiskUsage(now time.Time) {
+	archives, err := l.Archives()
+	if nil != err {
+		return
+	}
+	var total int64
+	for _, a := range archives {
+		total += a.Size
+	}
+	if total <= l.rotateCfg.UsageWarnBytes {
+		return
+	}
+
+	window := l.rotateCfg.UsageWarnInterval
+	if window <= 0 {
+		window = time.Hour
+	}
+	l.mu.Lock()
+	if !l.lastUsageWarn.IsZero() && now.Sub(l.lastUsageWarn) < window {
+		l.mu.Unlock()
+		return
+	}
+	l.lastUsageWarn = now
+	l.mu.Unlock()
+
+	l.Warning("log archives use %d bytes, over the %d bytes threshold", total, l.rotateCfg.UsageWarnBytes)
+}
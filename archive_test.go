@@ -0,0 +1,218 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchives(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	seed := []struct {
+		age  time.Duration
+		ext  string
+		data string
+	}{
+		{2 * time.Hour, ".gz", "old"},
+		{0, "", "newest"},
+		{time.Hour, "", "middle!"},
+	}
+	for _, s := range seed {
+		fn := logFile + "." + base.Add(-s.age).Format(formatMin) + s.ext
+		if err = ioutil.WriteFile(fn, []byte(s.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(filepath.Dir(logFile), "unrelated.txt"), nil, 0644)
+
+	logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5})
+	archives, err := logger.Archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ArchiveInfo{
+		{logFile + ".202610161200", base, 6, false, false, ""},
+		{logFile + ".202610161100", base.Add(-time.Hour), 7, false, false, ""},
+		{logFile + ".202610161000.gz", base.Add(-2 * time.Hour), 3, true, false, ""},
+	}
+	if len(archives) != len(want) {
+		t.Fatalf("got %+v", archives)
+	}
+	for i := range want {
+		a := archives[i]
+		if a.Path != want[i].Path || !a.Time.Equal(want[i].Time) || a.Size != want[i].Size || a.Compressed != want[i].Compressed {
+			t.Errorf("archive %d: got %+v, want %+v", i, a, want[i])
+		}
+	}
+}
+
+func TestDiskUsageWarning(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		fn := logFile + "." + now.Add(-time.Duration(i)*time.Hour).Format(formatMin)
+		ioutil.WriteFile(fn, make([]byte, 100), 0644)
+	}
+
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 5, UsageWarnBytes: 250, UsageWarnInterval: time.Minute}
+	logger := New(f, "", 0, LevelDebug, rc)
+	for _, at := range []time.Duration{0, time.Second, 59 * time.Second, time.Minute, 90 * time.Second} {
+		logger.checkDiskUsage(now.Add(at))
+	}
+
+	data, _ := ioutil.ReadFile(logFile)
+	if n := strings.Count(string(data), "[Warning] log archives use 300 bytes"); n != 2 {
+		t.Errorf("want 2 warnings, got %d: %q", n, data)
+	}
+}
+
+// dailyPolicy keeps the newest archive of each day.
+type dailyPolicy struct{}
+
+func (dailyPolicy) Select(archives []ArchiveInfo, now time.Time) (delete []string) {
+	seen := make(map[string]bool)
+	for _, a := range archives {
+		day := a.Time.Format("20060102")
+		if seen[day] {
+			delete = append(delete, a.Path)
+		}
+		seen[day] = true
+	}
+	return
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	var want []string
+	for _, age := range []time.Duration{0, 1, 2, 24, 25, 48} {
+		fn := logFile + "." + base.Add(-age*time.Hour).Format(formatMin)
+		ioutil.WriteFile(fn, nil, 0644)
+		if age == 0 || age == 24 || age == 48 {
+			want = append(want, fn)
+		}
+	}
+
+	// the default policy would remove everything a year later
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 1, Retention: dailyPolicy{}})
+	removed, _, err := logger.cleanOldLogs(base.AddDate(1, 0, 0), logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := filepath.Glob(logFile + ".*")
+	sort.Strings(want)
+	if removed != 3 || strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("removed %d, kept %v, want %v", removed, got, want)
+	}
+}
+
+func TestMinKeep(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	var want []string
+	for age := 1; age <= 5; age++ {
+		fn := logFile + "." + base.Add(-time.Duration(age)*24*time.Hour).Format(formatMin)
+		ioutil.WriteFile(fn, nil, 0644)
+		if age <= 2 {
+			want = append(want, fn)
+		}
+	}
+
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxAge: time.Hour, MinKeep: 2})
+	removed, _, err := logger.cleanOldLogs(base, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := filepath.Glob(logFile + ".*")
+	sort.Strings(want)
+	if removed != 3 || strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("removed %d, kept %v, want %v", removed, got, want)
+	}
+}
+
+func TestScanArchivesIgnoresNeighbours(t *testing.T) {
+	defer func(f func(string) (uint64, bool)) { diskFree = f }(diskFree)
+	diskFree = func(string) (uint64, bool) { return 0, true } // always short of space
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	now := time.Now()
+	suffix := now.Format(formatMin)
+	own := []string{logFile + "." + suffix, logFile + "." + suffix + ".3.gz", logFile + "." + suffix + ".gz.enc"}
+	neighbours := []string{
+		filepath.Join(dir, "other.log."+suffix),
+		filepath.Join(dir, "db-backup-"+suffix+".sql"),
+		filepath.Join(dir, "app.log."+suffix+".bak"),
+		filepath.Join(dir, "xapp.log."+suffix),
+	}
+	for _, fn := range append(own, neighbours...) {
+		ioutil.WriteFile(fn, []byte("x"), 0644)
+	}
+
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, MinFreeBytes: 1})
+	archives, err := logger.scanArchives(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != len(own) {
+		t.Errorf("got %+v, want only %v", archives, own)
+	}
+
+	removed, _, _ := logger.cleanOldLogs(now, logFile)
+	if removed != len(own) {
+		t.Errorf("removed %d files, want %d", removed, len(own))
+	}
+	for _, fn := range neighbours {
+		if _, err := os.Stat(fn); err != nil {
+			t.Errorf("neighbour %s was touched: %v", fn, err)
+		}
+	}
+}
+
+func TestCleanupBeforeRotate(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		logFile = filepath.Join(dir, "app.log")
+		now     = time.Now()
+		old     = logFile + "." + now.Add(-time.Minute).Format(formatSec)
+		recent  = logFile + "." + now.Add(-5*time.Second).Format(formatSec)
+		minute  = logFile + "." + now.Add(-time.Hour).Format(formatMin)
+	)
+	for _, fn := range []string{old, recent, minute} {
+		ioutil.WriteFile(fn, nil, 0644)
+	}
+
+	// a fresh Logger knows its suffix layout without a Rotate first
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: 10 * time.Second, MaxBackups: 2})
+	if removed, _, err := logger.cleanOldLogs(now, logFile); removed != 1 || err != nil {
+		t.Fatalf("removed %d, %v", removed, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expired archive kept: %v", err)
+	}
+	for _, fn := range []string{recent, minute} {
+		if _, err := os.Stat(fn); err != nil {
+			t.Errorf("%s removed: %v", fn, err)
+		}
+	}
+}
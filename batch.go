@@ -0,0 +1,100 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"sync/atomic"
+)
+
+// WriteBatch logs each of msgs, as is, at level and returns how many
+// passed the level and the Filter. The records are formatted first and
+// written together under a single lock, so a rotation falls before or
+// after the whole batch. A LevelWriter output gets them one by one.
+func (l *Logger) WriteBatch(level Level, msgs []string) (int, error) {
+	if !l.enabled(level) {
+		return 0, nil
+	}
+	defer l.report(true)
+	if l.FlushOnLevel > LevelDebug && level >= l.FlushOnLevel {
+		defer l.flush()
+	}
+
+	var (
+		buf     bytes.Buffer
+		std     = log.New(&buf, l.Prefix(), l.Flags())
+		filter  Filter
+		n       int
+		sink    io.Writer
+		records [][]byte // for a LevelWriter output
+	)
+	if l.SinkFactory != nil {
+		sink = l.levelSink(level)
+	}
+	_, leveled := l.Writer().(LevelWriter)
+	leveled = leveled && sink == nil
+	filter, _ = l.filter.Load().(Filter)
+	for _, s := range msgs {
+		if !l.sample(level) {
+			continue
+		}
+		if filter != nil && !l.filterKeeps(filter, level, s) {
+			atomic.AddUint64(&l.base().dropFiltered, 1)
+			continue
+		}
+		if leveled {
+			records = append(records, l.levelRecord(l.decorate(s), nil))
+		} else {
+			l.appendRecord(&buf, std, 3, level, s, nil)
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	for _, p := range records {
+		if _, err := l.writeRecord(level, p); nil != err {
+			return 0, err
+		}
+	}
+	if leveled {
+		return n, nil
+	}
+	write := l.writeRaw
+	if sink != nil {
+		write = sink.Write
+	}
+	if _, err := write(buf.Bytes()); nil != err {
+		return 0, err
+	}
+	return n, nil
+}
+
+// appendRecord formats s to buf as emit would write it, std being a
+// log.Logger on buf with the Logger's prefix and flags.
+func (l *Logger) appendRecord(buf *bytes.Buffer, std *log.Logger, calldepth int, level Level, s string, fields []Field) {
+	s = l.decorate(s)
+	if l.Format == FormatBinary && l.Encoder == nil {
+		buf.Write(binaryFrame(level, s+textFields(fields)))
+		return
+	}
+
+	start, text := buf.Len(), s+textFields(fields)
+	switch {
+	case l.Encoder != nil:
+		buf.Write(l.Encoder(nil, l.now(l.Flags()), level, s, fields))
+	case l.Format == FormatLogfmt:
+		buf.Write(l.formatLogfmt(calldepth, level, s, fields))
+	case l.LineFormat != "":
+		buf.WriteString(l.formatLine(calldepth, level, text))
+	case l.TimeZone != nil:
+		buf.WriteString(l.formatText(calldepth, level, text))
+	default:
+		std.Output(calldepth, l.levelTag(level)+tagSep+text)
+	}
+	if max := l.MaxMessageBytes; max > 0 && buf.Len()-start > max {
+		p := truncateRecord(append([]byte(nil), buf.Bytes()[start:]...), max)
+		buf.Truncate(start)
+		buf.Write(p)
+	}
+}
@@ -0,0 +1,44 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestWriteBatch(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "app: ", log.Lmsgprefix, LevelInfo, nil)
+
+	if n, err := logger.WriteBatch(LevelDebug, []string{"hidden"}); n != 0 || err != nil || buf.Len() != 0 {
+		t.Fatalf("Debug batch wrote %d %v %q", n, err, buf.String())
+	}
+	logger.SetFilter(func(level Level, msg string) bool { return msg != "drop" })
+	n, err := logger.WriteBatch(LevelInfo, []string{"one", "drop", "two", "three"})
+	if n != 3 || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if want := "app: [Info] one\napp: [Info] two\napp: [Info] three\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+var batchMsgs = []string{"hello log 1", "hello log 2", "hello log 3", "hello log 4",
+	"hello log 5", "hello log 6", "hello log 7", "hello log 8"}
+
+func BenchmarkWriteBatch(b *testing.B) {
+	l := New(ioutil.Discard, "prefix ", log.Ldate|log.Ltime, LevelInfo, nil)
+	for i := 0; i < b.N; i++ {
+		l.WriteBatch(LevelInfo, batchMsgs)
+	}
+}
+
+func BenchmarkWriteBatchSingle(b *testing.B) {
+	l := New(ioutil.Discard, "prefix ", log.Ldate|log.Ltime, LevelInfo, nil)
+	for i := 0; i < b.N; i++ {
+		for _, msg := range batchMsgs {
+			l.Info("%s", msg)
+		}
+	}
+}
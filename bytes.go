@@ -0,0 +1,29 @@
+package rotatelog
+
+// Write logs p at level as is, without format verbs or a conversion to
+// string, keeping NUL bytes and invalid UTF-8. A newline is added unless
+// p ends with one. Text records without an Encoder, SinkFactory, Filter,
+// Sampling, IncludeSeq, IncludeGID or Named tag are built with a single
+// copy of p.
+func (l *Logger) Write(level Level, p []byte) error {
+	if !l.enabled(level) {
+		return nil
+	}
+	filter, _ := l.filter.Load().(Filter)
+	_, leveled := l.Writer().(LevelWriter)
+	if l.Format != FormatText || l.LineFormat != "" || filter != nil || l.IncludeSeq || l.IncludeGID || leveled ||
+		len(l.Sampling) > 0 || l.name != "" || l.Encoder != nil || l.SinkFactory != nil {
+		return l.output(3, level, string(p))
+	}
+
+	defer l.report(true)
+	if l.FlushOnLevel > LevelDebug && level >= l.FlushOnLevel {
+		defer l.flush()
+	}
+	b := append(l.appendHeader(make([]byte, 0, 64+len(p)), 2, level), p...)
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	_, err := recordWriter{l}.Write(b)
+	return err
+}
@@ -0,0 +1,53 @@
+package rotatelog
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestWriteBytes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "app: ", log.Lshortfile, LevelInfo, nil)
+	payload := []byte("a\x00b\xff\xfe%s%d")
+
+	if err := logger.Write(LevelDebug, payload); err != nil || buf.Len() != 0 {
+		t.Fatalf("Debug written: %v %q", err, buf.String())
+	}
+	logger.Write(LevelWarning, payload)
+	logger.Write(LevelError, []byte("done\n"))
+
+	want := "app: bytes_test.go:17: [Warning] a\x00b\xff\xfe%s%d\n" +
+		"app: bytes_test.go:18: [Error] done\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	// a Filter takes the string path, with the same caller
+	buf.Reset()
+	logger.SetFilter(func(level Level, msg string) bool { return true })
+	logger.Write(LevelInfo, []byte("filtered"))
+	if want = "app: bytes_test.go:29: [Info] filtered\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteBytesSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelInfo, nil)
+	logger.Sampling = map[Level]Sample{LevelInfo: {First: 1, Thereafter: 1000}}
+	for i := 0; i < 5; i++ {
+		logger.Write(LevelInfo, []byte("sampled"))
+	}
+	if want := "[Info] sampled\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteBytesNamed(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, "", 0, LevelInfo, nil).Named("db").Write(LevelInfo, []byte("via write"))
+	if want := "[Info] [db] via write\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
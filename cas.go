@@ -0,0 +1,131 @@
+package rotatelog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// casIndex is the file of a directory mapping ContentAddressed archive
+// names to the files holding them, one "name file" line each.
+const casIndex = "cas.index"
+
+// contentSum returns the hex sha256 of the plain archive path, or "" unless
+// ContentAddressed applies.
+func (l *Logger) contentSum(path string) string {
+	if !l.rotateCfg.ContentAddressed || len(l.rotateCfg.EncryptKey) > 0 {
+		return ""
+	}
+	f, err := os.Open(path)
+	if nil != err {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); nil != err {
+		l.Error("hash archive err:%s", err.Error())
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// addressContent moves the compressed archive target to its sum, or
+// removes it if that file exists already, and records the mapping. It
+// returns the path now holding the archive.
+func (l *Logger) addressContent(target, sum string) string {
+	if sum == "" {
+		return target
+	}
+	l = l.base()
+	l.casMu.Lock()
+	defer l.casMu.Unlock()
+
+	var (
+		dir     = filepath.Dir(target)
+		name    = sum + "." + l.rotateCfg.compressExt()
+		content = filepath.Join(dir, name)
+	)
+	if _, err := os.Stat(content); nil == err {
+		os.Remove(target)
+	} else if err = os.Rename(target, content); nil != err {
+		l.Error("rename archive to its content err:%s", err.Error())
+		return target
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, casIndex), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if nil == err {
+		_, err = fmt.Fprintf(f, "%s %s\n", filepath.Base(target), name)
+		if cerr := f.Close(); nil == err {
+			err = cerr
+		}
+	}
+	if nil != err {
+		l.Error("update %s err:%s", casIndex, err.Error())
+	}
+	return content
+}
+
+// readIndex reads the casIndex of dir.
+func readIndex(dir string) map[string]string {
+	f, err := os.Open(filepath.Join(dir, casIndex))
+	if nil != err {
+		return nil
+	}
+	defer f.Close()
+	index := map[string]string{}
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		if fields := strings.Fields(sc.Text()); len(fields) == 2 {
+			index[fields[0]] = fields[1]
+		}
+	}
+	return index
+}
+
+// unindex drops the ContentAddressed archive fn from the index and removes
+// its file once no other archive maps to it. It returns the bytes freed,
+// ok is false if fn isn't indexed.
+func (l *Logger) unindex(fn string) (size int64, ok bool) {
+	l = l.base()
+	l.casMu.Lock()
+	defer l.casMu.Unlock()
+
+	var (
+		dir   = filepath.Dir(fn)
+		index = readIndex(dir)
+		name  = filepath.Base(fn)
+	)
+	content, ok := index[name]
+	if !ok {
+		return
+	}
+	delete(index, name)
+
+	var b strings.Builder
+	shared := false
+	for k, v := range index {
+		fmt.Fprintf(&b, "%s %s\n", k, v)
+		shared = shared || v == content
+	}
+	path := filepath.Join(dir, casIndex)
+	if err := ioutil.WriteFile(path+".tmp", []byte(b.String()), 0644); nil != err {
+		return 0, false
+	}
+	if err := os.Rename(path+".tmp", path); nil != err {
+		return 0, false
+	}
+	if shared {
+		return 0, true
+	}
+	content = filepath.Join(dir, content)
+	if fi, err := os.Stat(content); nil == err {
+		size = fi.Size()
+	}
+	os.Remove(content)
+	return size, true
+}
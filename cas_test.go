@@ -0,0 +1,75 @@
+package rotatelog
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContentAddressed(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 10, Compress: true, ContentAddressed: true, SyncMaintenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	for _, msg := range []string{"idle", "idle", "busy"} {
+		logger.Info("%s", msg)
+		if err := logger.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, _ := filepath.Glob(filepath.Join(dir, "*.gz"))
+	if len(stored) != 2 {
+		t.Fatalf("stored %v, want the two distinct contents", stored)
+	}
+	for _, fn := range stored {
+		if len(filepath.Base(fn)) != 64+len(".gz") {
+			t.Errorf("%s is not named by its sha256", fn)
+		}
+	}
+
+	archives, err := logger.Archives()
+	if err != nil || len(archives) != 3 {
+		t.Fatalf("Archives() = %v, %v, want 3", archives, err)
+	}
+	busy, idle := archives[0], archives[1]
+	if idle.Content != archives[2].Content || busy.Content == idle.Content {
+		t.Fatalf("identical archives must share their file: %+v", archives)
+	}
+	f, err := os.Open(busy.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != "[Info] busy\n" {
+		t.Errorf("%s holds %q", busy.Content, b)
+	}
+
+	// the shared file goes with the last archive naming it
+	if _, ok := logger.removeFile(idle.Path); !ok {
+		t.Fatalf("removeFile(%s) failed", idle.Path)
+	}
+	if _, err := os.Stat(idle.Content); err != nil {
+		t.Errorf("shared content removed early: %v", err)
+	}
+	if size, ok := logger.removeFile(archives[2].Path); !ok || size == 0 {
+		t.Errorf("removeFile(%s) = %d, %v", archives[2].Path, size, ok)
+	}
+	if _, err := os.Stat(idle.Content); !os.IsNotExist(err) {
+		t.Errorf("unreferenced content kept: %v", err)
+	}
+	if archives, _ := logger.Archives(); len(archives) != 1 || archives[0].Path != busy.Path {
+		t.Errorf("Archives() after cleanup = %+v", archives)
+	}
+}
@@ -0,0 +1,72 @@
+package rotatelog
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// WithLevel returns a child Logger with its own level threshold, e.g. to
+// log a flagged request verbosely. It starts from the settings of l and
+// writes through l, sharing its output: Rotate, Reopen and SetOutput act on
+// l, StartRotate and the like are to be called on l.
+func (l *Logger) WithLevel(level Level) *Logger {
+	c := l.child()
+	c.Level = level
+	return c
+}
+
+// Named returns a child Logger, as WithLevel does, tagging its records
+// with [name] after the level tag. Names of nested children are joined
+// with dots, e.g. [app.db].
+func (l *Logger) Named(name string) *Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	c := l.child()
+	c.name = name
+	return c
+}
+
+// child copies the settings of l into a Logger writing through l.
+func (l *Logger) child() *Logger {
+	root := l.base()
+	c := &Logger{
+		Level:           l.level(),
+		Format:          l.Format,
+		MaxMessageBytes: l.MaxMessageBytes,
+		LineFormat:      l.LineFormat,
+		SchemaVersion:   l.SchemaVersion,
+		Encoder:         l.Encoder,
+		TimeZone:        l.TimeZone,
+		ErrorHandler:    l.ErrorHandler,
+		CheckFormat:     l.CheckFormat,
+		SinkFactory:     l.SinkFactory,
+		StackLevel:      l.StackLevel,
+		StackDepth:      l.StackDepth,
+		Sampling:        l.Sampling,
+		FlushOnLevel:    l.FlushOnLevel,
+		IncludeSeq:      l.IncludeSeq,
+		IncludeGID:      l.IncludeGID,
+		name:            l.name,
+		root:            root,
+		rotateCfg:       l.rotateCfg,
+	}
+	c.Logger = log.New(recordWriter{c}, l.Prefix(), l.Flags())
+	c.enabledMask = atomic.LoadUint32(&l.enabledMask)
+	c.disabledMask = atomic.LoadUint32(&l.disabledMask)
+	if f, _ := l.filter.Load().(Filter); f != nil {
+		c.filter.Store(f)
+	}
+	if tags := l.tags.Load(); tags != nil {
+		c.tags.Store(tags)
+	}
+	return c
+}
+
+// base returns the Logger holding the output of l.
+func (l *Logger) base() *Logger {
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
@@ -0,0 +1,59 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	parent := New(&buf, "app: ", 0, LevelInfo, nil)
+	parent.IncludeSeq = true
+	child := parent.WithLevel(LevelDebug)
+
+	parent.Debug("parent debug")
+	child.Debug("child debug")
+	parent.Info("parent info")
+	child.Info("child info")
+
+	want := "app: [Debug] seq=1 child debug\n" +
+		"app: [Info] seq=2 parent info\n" +
+		"app: [Info] seq=3 child info\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	if child.Writer() != &buf {
+		t.Errorf("child writes to %v", child.Writer())
+	}
+}
+
+func TestNamed(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	parent, err := NewFile(logFile, "", 0, LevelInfo, &RotateConfig{Duration: time.Hour, MaxBackups: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	db := parent.Named("app").Named("db")
+
+	db.Info("before")
+	if err := db.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	db.Warning("after")
+	parent.Info("plain")
+
+	archives, _ := parent.Archives()
+	if len(archives) != 1 {
+		t.Fatalf("archives %v, the child must rotate the parent's file", archives)
+	}
+	if b, _ := ioutil.ReadFile(archives[0].Path); string(b) != "[Info] [app.db] before\n" {
+		t.Errorf("archive has %q", b)
+	}
+	if b, _ := ioutil.ReadFile(logFile); string(b) != "[Warning] [app.db] after\n[Info] plain\n" {
+		t.Errorf("log file has %q", b)
+	}
+}
@@ -0,0 +1,70 @@
+package rotatelog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// compressExt returns the extension of compressed archives, without dot.
+func (rc *RotateConfig) compressExt() string {
+	if len(rc.Pipeline) > 0 {
+		return rc.pipelineExt()
+	}
+	if len(rc.CompressCommand) > 0 && rc.CompressExt != "" {
+		return rc.CompressExt
+	}
+	return "gz"
+}
+
+// compressCommand pipes path through CompressCommand into
+// path.CompressExt, removing path on success.
+func (l *Logger) compressCommand(path string) (err error) {
+	var (
+		argv   = l.rotateCfg.CompressCommand
+		out    = fmt.Sprintf("%s.%s", path, l.rotateCfg.compressExt())
+		stderr bytes.Buffer
+	)
+
+	in, err := os.Open(path)
+	if nil != err {
+		l.Error("open file for compress err:%s", err.Error())
+		return
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if nil != err {
+		l.Error("stat file for compress err:%s", err.Error())
+		return
+	}
+
+	wf, err := os.OpenFile(out, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if nil != err {
+		l.Error("open compressed file err:%s", err.Error())
+		return
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = in, wf, &stderr
+	err = cmd.Run()
+	if cerr := wf.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		err = fmt.Errorf("compress command %q: %v", strings.Join(argv, " "), err)
+		l.Error("%s", err.Error())
+		os.Remove(out)
+		return
+	}
+
+	os.Chtimes(out, fi.ModTime(), fi.ModTime())
+	if len(l.rotateCfg.EncryptKey) > 0 {
+		return wipeFile(path)
+	}
+	return os.Remove(path)
+}
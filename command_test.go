@@ -0,0 +1,54 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressCommand(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("no cat command")
+	}
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, CompressCommand: []string{"cat"}, CompressExt: "cat"}
+	logger := New(f, "", 0, LevelDebug, rc)
+	logger.Info("piped")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+
+	archives, _ := logger.Archives()
+	if len(archives) != 1 || !strings.HasSuffix(archives[0].Path, ".cat") || !archives[0].Compressed {
+		t.Fatalf("want one .cat archive, got %+v", archives)
+	}
+	if data, _ := ioutil.ReadFile(archives[0].Path); string(data) != "[Info] piped\n" {
+		t.Errorf("archive content %q", data)
+	}
+
+	plain := filepath.Join(dir, "app.log.202610161200")
+	for _, argv := range [][]string{{"no-such-compressor-cmd"}, {"sh", "-c", "echo broken >&2; exit 3"}} {
+		ioutil.WriteFile(plain, []byte("kept"), 0644)
+		rc.CompressCommand = argv
+		err = logger.compressCommand(plain)
+		if err == nil || !strings.Contains(err.Error(), argv[0]) {
+			t.Errorf("%v: unclear error %v", argv, err)
+		}
+		if _, serr := os.Stat(plain); serr != nil {
+			t.Errorf("%v: raw archive removed on failure", argv)
+		}
+		if _, serr := os.Stat(plain + ".cat"); !os.IsNotExist(serr) {
+			t.Errorf("%v: partial output left", argv)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package rotatelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// LoadRotateConfig decodes a JSON RotateConfig from r, see the field tags.
+// Durations are Go duration strings such as "24h" or "90s", unknown keys
+// are rejected.
+func LoadRotateConfig(r io.Reader) (*RotateConfig, error) {
+	rc := &RotateConfig{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(rc.fields()); nil != err {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// UnmarshalJSON decodes the RotateConfig fields with durations given as
+// strings.
+func (rc *RotateConfig) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, rc.fields())
+}
+
+// fields returns what the JSON form of rc decodes into.
+func (rc *RotateConfig) fields() interface{} {
+	type plain RotateConfig
+	aux := struct {
+		*plain
+		Duration          jsonDuration `json:"duration"`
+		WatchInterval     jsonDuration `json:"watch_interval"`
+		LazyIdle          jsonDuration `json:"lazy_idle"`
+		TrashRetention    jsonDuration `json:"trash_retention"`
+		CheckInterval     jsonDuration `json:"check_interval"`
+		SyncInterval      jsonDuration `json:"sync_interval"`
+		UsageWarnInterval jsonDuration `json:"usage_warn_interval"`
+		MaxAge            jsonDuration `json:"max_age"`
+	}{
+		plain:             (*plain)(rc),
+		Duration:          jsonDuration{&rc.Duration},
+		WatchInterval:     jsonDuration{&rc.WatchInterval},
+		LazyIdle:          jsonDuration{&rc.LazyIdle},
+		TrashRetention:    jsonDuration{&rc.TrashRetention},
+		CheckInterval:     jsonDuration{&rc.CheckInterval},
+		SyncInterval:      jsonDuration{&rc.SyncInterval},
+		UsageWarnInterval: jsonDuration{&rc.UsageWarnInterval},
+		MaxAge:            jsonDuration{&rc.MaxAge},
+	}
+	return &aux
+}
+
+// jsonDuration decodes a duration string into d.
+type jsonDuration struct {
+	d *time.Duration
+}
+
+func (j jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); nil != err {
+		return fmt.Errorf("duration must be a string such as \"1h\": %s", data)
+	}
+	d, err := time.ParseDuration(s)
+	if nil != err {
+		return err
+	}
+	*j.d = d
+	return nil
+}
@@ -0,0 +1,49 @@
+package rotatelog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRotateConfig(t *testing.T) {
+	rc, err := LoadRotateConfig(strings.NewReader(`{
+		"max_backups": 7,
+		"duration": "1h",
+		"compress": true,
+		"max_age": "168h",
+		"check_interval": "1m30s",
+		"sync_interval": "1s",
+		"trash_retention": "24h",
+		"compress_command": ["xz", "-9"],
+		"collision": 2
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RotateConfig{
+		MaxBackups:      7,
+		Duration:        time.Hour,
+		Compress:        true,
+		MaxAge:          7 * 24 * time.Hour,
+		CheckInterval:   90 * time.Second,
+		SyncInterval:    time.Second,
+		TrashRetention:  24 * time.Hour,
+		CompressCommand: []string{"xz", "-9"},
+		Collision:       CollisionError,
+	}
+	if !reflect.DeepEqual(rc, want) {
+		t.Errorf("got %+v, want %+v", rc, want)
+	}
+
+	for _, blob := range []string{
+		`{"duration": 3600}`,
+		`{"duration": "1 hour"}`,
+		`{"max_backup": 7}`,
+	} {
+		if _, err = LoadRotateConfig(strings.NewReader(blob)); err == nil {
+			t.Errorf("%s: no error", blob)
+		}
+	}
+}
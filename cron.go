@@ -0,0 +1,163 @@
+package rotatelog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five field cron spec: minute, hour, day of
+// month, month and day of week, each as a bit set.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	domStar, dowStar bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses specs such as "0 2 * * *" or "*/15 9-17 * * 1-5".
+// Fields take *, numbers, ranges, lists and /steps, day of week 7 is
+// Sunday like 0. The @daily style shorthands are accepted too.
+func parseCron(spec string) (*cronSchedule, error) {
+	if d, ok := cronDescriptors[spec]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron spec %q: want 5 fields, got %d", spec, len(fields))
+	}
+
+	var (
+		c   cronSchedule
+		err error
+	)
+	bounds := []struct {
+		set      *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.set, err = parseCronField(fields[i], b.min, b.max); nil != err {
+			return nil, fmt.Errorf("cron spec %q: %v", spec, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	c.dowStar = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+	return &c, nil
+}
+
+func parseCronField(field string, min, max int) (set uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		var (
+			rng  = part
+			step = 1
+			lo   = min
+			hi   = max
+		)
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); nil != err || step <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+		}
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = strconv.Atoi(bounds[0]); nil != err {
+				return 0, fmt.Errorf("bad value in %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); nil != err {
+					return 0, fmt.Errorf("bad value in %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first matching minute after t, or the zero time if the
+// spec can't match within five years.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// parseCron parses RotateConfig.Cron, which needs MaxAge for retention.
+func (l *Logger) parseCron() (err error) {
+	if l.rotateCfg.MaxAge <= 0 {
+		return errInvalidRotateConfig
+	}
+	if l.cron, err = parseCron(l.rotateCfg.Cron); nil != err {
+		return
+	}
+	if l.cron.next(time.Now()).IsZero() {
+		return fmt.Errorf("cron spec %q never matches", l.rotateCfg.Cron)
+	}
+	return
+}
+
+// nextRotation returns when StartRotate rotates next after now.
+func (l *Logger) nextRotation(now time.Time) time.Time {
+	if l.cron != nil {
+		return l.cron.next(now)
+	}
+	return l.rotateCfg.nextPeriod(now)
+}
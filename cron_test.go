@@ -0,0 +1,83 @@
+package rotatelog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if nil != err {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		spec string
+		now  string
+		want []string
+	}{
+		{"*/15 * * * *", "2024-03-01 10:07", []string{"2024-03-01 10:15", "2024-03-01 10:30", "2024-03-01 10:45", "2024-03-01 11:00"}},
+		{"0 2 * * *", "2024-03-01 02:00", []string{"2024-03-02 02:00", "2024-03-03 02:00"}},
+		{"30 9-10 * * 1-5", "2024-03-01 10:30", []string{"2024-03-04 09:30", "2024-03-04 10:30", "2024-03-05 09:30"}},
+		{"0 0 29 2 *", "2024-03-01 00:00", []string{"2028-02-29 00:00"}},
+		{"0 0 1 * 0", "2024-03-01 00:00", []string{"2024-03-03 00:00", "2024-03-10 00:00"}},
+		{"@daily", "2024-12-31 23:59", []string{"2025-01-01 00:00"}},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.spec)
+		if nil != err {
+			t.Fatalf("%q: %v", tt.spec, err)
+		}
+		clock := at(tt.now)
+		for _, w := range tt.want {
+			clock = c.next(clock)
+			if !clock.Equal(at(w)) {
+				t.Errorf("%q: got %v, want %s", tt.spec, clock, w)
+				break
+			}
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); nil == err {
+			t.Errorf("%q: want error", spec)
+		}
+	}
+}
+
+func TestCronRotation(t *testing.T) {
+	l := New(nil, "", 0, LevelInfo, &RotateConfig{Cron: "0 */6 * * *", MaxAge: 48 * time.Hour})
+	if _, err := l.StartRotate(); nil != err {
+		t.Fatal(err)
+	}
+	defer l.Stop()
+
+	clock := time.Date(2024, 3, 1, 5, 59, 30, 0, time.UTC)
+	for _, want := range []int{6, 12, 18, 24} {
+		next := l.nextRotation(clock)
+		if exp := time.Date(2024, 3, 1, want, 0, 0, 0, time.UTC); !next.Equal(exp) {
+			t.Fatalf("next rotation after %v = %v, want %v", clock, next, exp)
+		}
+		clock = next
+	}
+	if got := l.genSuffixStr(); len(got) != len(formatMin) {
+		t.Errorf("suffix %q should be minute precision", got)
+	}
+
+	if !l.isOverdue(clock, clock.Add(-49*time.Hour)) || l.isOverdue(clock, clock.Add(-47*time.Hour)) {
+		t.Error("retention should follow MaxAge")
+	}
+
+	for _, rc := range []*RotateConfig{
+		{Cron: "0 2 * * *"},
+		{Cron: "0 2 * *", MaxAge: time.Hour},
+		{Cron: "0 0 31 2 *", MaxAge: time.Hour},
+	} {
+		if _, err := New(nil, "", 0, LevelInfo, rc).StartRotate(); nil == err {
+			t.Errorf("%+v: want error", rc)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package rotatelog
+
+import (
+	"errors"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// diskFullRetry is how long writes are dropped after one failed for lack
+// of space before the next is tried.
+var diskFullRetry = time.Second
+
+var errDiskFull = errors.New("disk full, record dropped")
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// writeFull is called with l.mu held when a write failed with err for lack
+// of space. It enters or stays in degraded mode, in which writes are dropped
+// and only retried every diskFullRetry, and starts an emergency cleanup if
+// asked.
+func (l *Logger) writeFull(now time.Time, err error) {
+	if l.fullSince.IsZero() {
+		l.fullSince = now
+		l.fullErr = err
+	}
+	l.fullRetry = now.Add(diskFullRetry)
+	l.dropped++
+	atomic.AddUint64(&l.dropDiskFull, 1)
+
+	if l.rotateCfg == nil || !l.rotateCfg.CleanOnDiskFull || l.fileName == "" {
+		return
+	}
+	fileName := l.fileName
+	l.pending.Add(1)
+	go func() {
+		defer l.pending.Done()
+		l.removeOldest(fileName)
+	}()
+}
+
+// report passes a pending disk full or Mirror error to the ErrorHandler
+// and, if records is set, logs the Warning closing degraded mode and the
+// switches to and from the Fallback, for the Mirror too. It must run after
+// the write that left them, outside of log.Logger.Output.
+func (l *Logger) report(records bool) {
+	l = l.base()
+	l.mu.Lock()
+	err, notice, switched, level := l.fullErr, l.fullNotice, l.fallbackNotice, l.fallbackLevel
+	mirrorErr := l.mirrorErr
+	l.fullErr, l.fullNotice, l.fallbackNotice, l.mirrorErr = nil, "", "", nil
+	l.mu.Unlock()
+
+	if nil != err {
+		l.handleError(err)
+	}
+	if nil != mirrorErr {
+		l.handleError(mirrorErr)
+	}
+	if l.Mirror != nil {
+		l.Mirror.report(records)
+	}
+	if records && notice != "" {
+		l.Warning("%s", notice)
+	}
+	if records && switched != "" {
+		l.log(level, "%s", switched)
+	}
+}
+
+// removeOldest removes the oldest archive of fileName.
+func (l *Logger) removeOldest(fileName string) {
+	archives, err := l.scanArchives(fileName)
+	if nil != err || len(archives) == 0 {
+		return
+	}
+	l.removeFile(archives[len(archives)-1].Path)
+}
@@ -0,0 +1,101 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fullDisk fails writes with ENOSPC while full is set.
+type fullDisk struct {
+	mu     sync.Mutex
+	name   string
+	full   bool
+	writes int
+	buf    bytes.Buffer
+}
+
+func (d *fullDisk) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.writes++
+	if d.full {
+		return 0, &os.PathError{Op: "write", Path: d.name, Err: syscall.ENOSPC}
+	}
+	return d.buf.Write(p)
+}
+
+func (d *fullDisk) Name() string { return d.name }
+
+func TestDiskFull(t *testing.T) {
+	defer func(d time.Duration) { diskFullRetry = d }(diskFullRetry)
+	diskFullRetry = 50 * time.Millisecond
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	for _, suffix := range []string{"202610161000", "202610161100"} {
+		ioutil.WriteFile(logFile+"."+suffix, nil, 0644)
+	}
+	disk := &fullDisk{name: logFile, full: true}
+	logger := New(disk, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, CleanOnDiskFull: true})
+	var reported []error
+	logger.ErrorHandler = func(err error) { reported = append(reported, err) }
+
+	logger.Info("lost")
+	logger.Info("dropped")
+	logger.Info("dropped")
+	logger.WaitPending()
+	if disk.writes != 1 {
+		t.Errorf("%d writes reached the full disk, want 1", disk.writes)
+	}
+	if len(reported) != 1 || !isDiskFull(reported[0]) {
+		t.Errorf("ErrorHandler got %v, want one ENOSPC", reported)
+	}
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 1 || !strings.HasSuffix(archives[0], "1100") {
+		t.Errorf("emergency cleanup should remove the oldest archive: %v", archives)
+	}
+
+	disk.mu.Lock()
+	disk.full = false
+	disk.mu.Unlock()
+	time.Sleep(diskFullRetry)
+	logger.Info("back")
+	logger.Info("again")
+
+	got := disk.buf.String()
+	if !strings.HasPrefix(got, "[Info] back\n[Warning] disk was full for ") || !strings.Contains(got, ", 3 records dropped\n[Info] again\n") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWriterDiskFull(t *testing.T) {
+	defer func(d time.Duration) { diskFullRetry = d }(diskFullRetry)
+	diskFullRetry = 50 * time.Millisecond
+
+	disk := &fullDisk{full: true}
+	w := &Writer{l: New(disk, "", 0, LevelDebug, nil)}
+	var reported int
+	w.l.ErrorHandler = func(error) { reported++ }
+
+	if _, err := w.Write([]byte("lost\n")); !isDiskFull(err) {
+		t.Errorf("first write: %v", err)
+	}
+	if _, err := w.Write([]byte("dropped\n")); err != errDiskFull {
+		t.Errorf("write while degraded: %v", err)
+	}
+
+	disk.mu.Lock()
+	disk.full = false
+	disk.mu.Unlock()
+	time.Sleep(diskFullRetry)
+	w.Write([]byte("raw\n"))
+	if got := disk.buf.String(); got != "raw\n" || reported != 1 {
+		t.Errorf("got %q with %d reports, the raw stream must stay unformatted", got, reported)
+	}
+}
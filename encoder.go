@@ -0,0 +1,34 @@
+package rotatelog
+
+import (
+	"sync"
+	"time"
+)
+
+// Encoder appends a whole record, newline included, to buf and returns
+// the extended buffer. msg carries the IncludeSeq, IncludeGID and Named
+// decorations, t is the record time as the flags and TimeZone give it.
+type Encoder func(buf []byte, t time.Time, level Level, msg string, fields []Field) []byte
+
+// maxPooledRecord bounds the buffers kept for reuse, so one huge record
+// doesn't pin its memory.
+const maxPooledRecord = 64 << 10
+
+var recordBufs = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// encode writes the record Encoder renders into a pooled buffer.
+func (l *Logger) encode(level Level, s string, fields []Field) error {
+	bp := recordBufs.Get().(*[]byte)
+	b := l.Encoder((*bp)[:0], l.now(l.Flags()), level, s, fields)
+	_, err := recordWriter{l}.Write(b)
+	if cap(b) <= maxPooledRecord {
+		*bp = b[:0]
+		recordBufs.Put(bp)
+	}
+	return err
+}
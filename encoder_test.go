@@ -0,0 +1,55 @@
+package rotatelog
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelInfo, nil)
+	logger.TimeZone = time.UTC
+	logger.Encoder = func(b []byte, ts time.Time, level Level, msg string, fields []Field) []byte {
+		b = append(b, '<')
+		b = strconv.AppendInt(b, int64(level), 10)
+		b = append(b, '>')
+		b = append(b, ts.Format("2006")...)
+		b = append(b, '|')
+		b = append(b, msg...)
+		for _, f := range fields {
+			b = append(b, '|')
+			b = append(b, f.Key...)
+		}
+		return append(b, '\n')
+	}
+
+	logger.Warning("disk %d%%", 91)
+	logger.Infow("request", Str("path", "/"), Int("status", 200))
+	logger.Debug("dropped")
+	if _, err := logger.WriteBatch(LevelError, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	year := time.Now().UTC().Format("2006")
+	want := "<" + strconv.Itoa(int(LevelWarning)) + ">" + year + "|disk 91%\n" +
+		"<" + strconv.Itoa(int(LevelInfo)) + ">" + year + "|request|path|status\n" +
+		"<" + strconv.Itoa(int(LevelError)) + ">" + year + "|a\n" +
+		"<" + strconv.Itoa(int(LevelError)) + ">" + year + "|b\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestEncoderWrite(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelInfo, nil)
+	logger.Encoder = func(b []byte, ts time.Time, level Level, msg string, fields []Field) []byte {
+		return append(append(append(b, level.Tag()...), '|'), msg+"\n"...)
+	}
+	logger.Write(LevelWarning, []byte("raw"))
+	if want := "[Warning]|raw\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,191 @@
+package rotatelog
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encrypted archives are a random nonce followed by chunks of at most
+// encryptChunk plaintext bytes, each sealed with AES-GCM and prefixed by
+// its sealed length. The chunk counter is mixed into the nonce and the
+// last chunk is marked in the additional data, so reordered or truncated
+// archives fail to decrypt.
+const encryptChunk = 64 << 10
+
+var errTruncatedArchive = errors.New("truncated encrypted archive")
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if nil != err {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(base []byte, n uint64) []byte {
+	nonce := append([]byte{}, base...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^n)
+	return nonce
+}
+
+func chunkAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// EncryptArchive encrypts r into w with key, see DecryptArchive.
+func EncryptArchive(key []byte, r io.Reader, w io.Writer) error {
+	aead, err := newGCM(key)
+	if nil != err {
+		return err
+	}
+
+	base := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(base); nil != err {
+		return err
+	}
+	if _, err = w.Write(base); nil != err {
+		return err
+	}
+
+	var (
+		buf  = make([]byte, encryptChunk)
+		next = make([]byte, encryptChunk)
+		size [4]byte
+	)
+	n, err := io.ReadFull(r, buf)
+	for i := uint64(0); ; i++ {
+		if nil != err && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		var m int
+		last := nil != err
+		if !last {
+			// peek ahead so the final chunk can be marked
+			m, err = io.ReadFull(r, next)
+			last = m == 0 && err == io.EOF
+		}
+
+		sealed := aead.Seal(nil, chunkNonce(base, i), buf[:n], chunkAD(last))
+		binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+		if _, werr := w.Write(size[:]); nil != werr {
+			return werr
+		}
+		if _, werr := w.Write(sealed); nil != werr {
+			return werr
+		}
+		if last {
+			return nil
+		}
+		buf, next, n = next, buf, m
+	}
+}
+
+// DecryptArchive decrypts an archive written with EncryptKey into w.
+func DecryptArchive(key []byte, r io.Reader, w io.Writer) error {
+	aead, err := newGCM(key)
+	if nil != err {
+		return err
+	}
+
+	base := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(r, base); nil != err {
+		return errTruncatedArchive
+	}
+
+	var size [4]byte
+	for i := uint64(0); ; i++ {
+		if _, err = io.ReadFull(r, size[:]); nil != err {
+			return errTruncatedArchive
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > encryptChunk+uint32(aead.Overhead()) {
+			return fmt.Errorf("invalid encrypted chunk size %d", n)
+		}
+		sealed := make([]byte, n)
+		if _, err = io.ReadFull(r, sealed); nil != err {
+			return errTruncatedArchive
+		}
+
+		nonce := chunkNonce(base, i)
+		plain, err := aead.Open(nil, nonce, sealed, chunkAD(false))
+		last := false
+		if nil != err {
+			if plain, err = aead.Open(nil, nonce, sealed, chunkAD(true)); nil != err {
+				return err
+			}
+			last = true
+		}
+		if _, err = w.Write(plain); nil != err {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// encrypt seals path into path.enc and wipes the plaintext on success.
+func (l *Logger) encrypt(path string) (err error) {
+	var (
+		rawfile *os.File
+		wf      *os.File
+		efn     = fmt.Sprintf("%s.enc", path)
+	)
+
+	rawfile, err = os.Open(path)
+	if nil != err {
+		l.Error("open file for encrypt err:%s", err.Error())
+		return
+	}
+	defer rawfile.Close()
+
+	wf, err = os.OpenFile(efn, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+	if nil != err {
+		l.Error("open enc file err:%s", err.Error())
+		return
+	}
+
+	err = EncryptArchive(l.rotateCfg.EncryptKey, rawfile, wf)
+	if cerr := wf.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		l.Error("write enc file:%s, err:%s", efn, err.Error())
+		os.Remove(efn)
+		return
+	}
+	return wipeFile(path)
+}
+
+// wipeFile overwrites the content of path with zeros before removing it.
+func wipeFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if nil != err {
+		return err
+	}
+	if fi, err := f.Stat(); nil == err {
+		io.CopyN(f, zeroReader{}, fi.Size())
+		f.Sync()
+	}
+	f.Close()
+	return os.Remove(path)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,105 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptArchive(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	for _, size := range []int{0, 10, encryptChunk, 3*encryptChunk + 5} {
+		path := filepath.Join(t.TempDir(), "app.log.202610161200.gz")
+		plain := make([]byte, size)
+		for i := range plain {
+			plain[i] = byte(i % 251)
+		}
+		if err := ioutil.WriteFile(path, plain, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, EncryptKey: key})
+		if err := logger.encrypt(path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("size %d: plaintext not removed: %v", size, err)
+		}
+
+		sealed, err := ioutil.ReadFile(path + ".enc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err = DecryptArchive(key, bytes.NewReader(sealed), &out); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(out.Bytes(), plain) {
+			t.Errorf("size %d: decrypted content differs", size)
+		}
+
+		if err = DecryptArchive(bytes.Repeat([]byte{8}, 32), bytes.NewReader(sealed), ioutil.Discard); err == nil {
+			t.Errorf("size %d: decrypted with the wrong key", size)
+		}
+		if size > encryptChunk {
+			truncated := sealed[:len(sealed)-encryptChunk]
+			if err = DecryptArchive(key, bytes.NewReader(truncated), ioutil.Discard); err == nil {
+				t.Errorf("size %d: truncated archive decrypted", size)
+			}
+		}
+	}
+}
+
+func TestRotateCompressEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, EncryptKey: key})
+	logger.Info("secret")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 || filepath.Ext(archives[0]) != ".enc" {
+		t.Fatalf("want only the sealed archive, got %v", archives)
+	}
+	sealed, _ := ioutil.ReadFile(archives[0])
+	var gz bytes.Buffer
+	if err = DecryptArchive(key, bytes.NewReader(sealed), &gz); err != nil {
+		t.Fatal(err)
+	}
+	if data := readGzip(t, writeTemp(t, gz.Bytes())); data != "[Info] secret\n" {
+		t.Errorf("archive content %q", data)
+	}
+
+	// the uncompressed plaintext is overwritten, not only unlinked
+	plain := filepath.Join(dir, "app.log.202610161200")
+	ioutil.WriteFile(plain, []byte("secret"), 0644)
+	os.Link(plain, plain+".link")
+	logger.archive(plain, false, false)
+	if data, _ := ioutil.ReadFile(plain + ".link"); !bytes.Equal(data, make([]byte, 6)) {
+		t.Errorf("plaintext left readable: %q", data)
+	}
+
+	merged := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, MergePeriod: true, EncryptKey: key})
+	if _, err = merged.StartRotate(); err != errInvalidRotateConfig {
+		t.Errorf("EncryptKey with MergePeriod: %v", err)
+	}
+}
+
+func writeTemp(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
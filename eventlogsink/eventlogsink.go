@@ -0,0 +1,60 @@
+// Package eventlogsink writes rotatelog records to the Windows Event Log,
+// keeping the golang.org/x/sys dependency out of the rotatelog package.
+//
+// Sink and New take any EventLog, such as an *eventlog.Log of
+// golang.org/x/sys/windows/svc/eventlog. Open and Remove use that package
+// directly and are only built on Windows with the eventlog build tag, as
+// in go build -tags eventlog, once golang.org/x/sys is on the GOPATH.
+package eventlogsink
+
+import (
+	"strings"
+
+	"github.com/dark-wing/rotatelog"
+)
+
+// EventLog is the part of eventlog.Log the Sink writes to.
+type EventLog interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+// Sink is a rotatelog.LevelWriter for the Event Log, give it to
+// rotatelog.New or SetOutput. Error and Critical map to Error events,
+// Warning to Warning and lower levels to Information.
+type Sink struct {
+	log     EventLog
+	eventID uint32
+}
+
+// New returns a Sink writing events with eventID to log.
+func New(log EventLog, eventID uint32) *Sink {
+	return &Sink{log: log, eventID: eventID}
+}
+
+func (s *Sink) WriteLevel(level rotatelog.Level, msg string) error {
+	msg = strings.TrimSuffix(msg, "\n")
+	switch {
+	case level >= rotatelog.LevelError:
+		return s.log.Error(s.eventID, msg)
+	case level == rotatelog.LevelWarning:
+		return s.log.Warning(s.eventID, msg)
+	default:
+		return s.log.Info(s.eventID, msg)
+	}
+}
+
+// Write logs p as Information, for raw writes such as Logger.Write.
+func (s *Sink) Write(p []byte) (int, error) {
+	if err := s.log.Info(s.eventID, strings.TrimSuffix(string(p), "\n")); nil != err {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the Event Log handle, the source stays registered.
+func (s *Sink) Close() error {
+	return s.log.Close()
+}
@@ -0,0 +1,64 @@
+package eventlogsink
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dark-wing/rotatelog"
+)
+
+// fakeLog records the events as "type eid msg".
+type fakeLog struct {
+	events []string
+	closed bool
+}
+
+func (f *fakeLog) add(typ string, eid uint32, msg string) error {
+	f.events = append(f.events, typ+" "+string(rune('0'+eid))+" "+msg)
+	return nil
+}
+
+func (f *fakeLog) Info(eid uint32, msg string) error    { return f.add("info", eid, msg) }
+func (f *fakeLog) Warning(eid uint32, msg string) error { return f.add("warning", eid, msg) }
+func (f *fakeLog) Error(eid uint32, msg string) error   { return f.add("error", eid, msg) }
+func (f *fakeLog) Close() error                         { f.closed = true; return nil }
+
+func TestLevelMapping(t *testing.T) {
+	el := &fakeLog{}
+	sink := New(el, 7)
+	l := rotatelog.New(sink, "app: ", 0, rotatelog.LevelDebug, &rotatelog.RotateConfig{Duration: time.Hour})
+
+	l.Debug("debug")
+	l.Info("info")
+	l.Notice("notice")
+	l.Warning("warning")
+	l.Error("error")
+	l.Critical("critical")
+	l.Write(rotatelog.LevelError, []byte("raw\n"))
+	if n, err := l.WriteBatch(rotatelog.LevelWarning, []string{"batch 1", "batch 2"}); n != 2 || err != nil {
+		t.Errorf("WriteBatch = %d, %v", n, err)
+	}
+	if err := l.Rotate(); err != nil {
+		t.Errorf("Rotate: %v", err)
+	}
+	sink.Close()
+
+	want := []string{
+		"info 7 app: debug",
+		"info 7 app: info",
+		"info 7 app: notice",
+		"warning 7 app: warning",
+		"error 7 app: error",
+		"error 7 app: critical",
+		"error 7 app: raw",
+		"warning 7 app: batch 1",
+		"warning 7 app: batch 2",
+	}
+	if !reflect.DeepEqual(el.events, want) {
+		t.Errorf("events:\n%q\nwant\n%q", el.events, want)
+	}
+	if !el.closed {
+		t.Error("Close should close the event log")
+	}
+}
@@ -0,0 +1,53 @@
+//go:build windows && eventlog
+// +build windows,eventlog
+
+package eventlogsink
+
+import (
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// sourcesKey is where eventlog installs the Application sources.
+const sourcesKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+
+// Open registers source in the registry unless it already is, which
+// needs administrator rights once, and returns a Sink writing to it.
+func Open(source string, eventID uint32) (*Sink, error) {
+	ok, err := installed(source)
+	if nil != err {
+		return nil, err
+	}
+	if !ok {
+		err = eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+		if nil != err {
+			// another process may have installed it meanwhile
+			if ok, _ = installed(source); !ok {
+				return nil, err
+			}
+		}
+	}
+	log, err := eventlog.Open(source)
+	if nil != err {
+		return nil, err
+	}
+	return New(log, eventID), nil
+}
+
+// installed reports whether source has its registry key.
+func installed(source string) (bool, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, sourcesKey+source, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return false, nil
+	}
+	if nil != err {
+		return false, err
+	}
+	k.Close()
+	return true, nil
+}
+
+// Remove deregisters source, the Sinks of it must be closed first.
+func Remove(source string) error {
+	return eventlog.Remove(source)
+}
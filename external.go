@@ -0,0 +1,75 @@
+package rotatelog
+
+import (
+	"os"
+	"time"
+)
+
+// Reopen reopens the current log file by path, picking up a file that was
+// renamed or replaced by someone else. It does nothing unless the output is
+// a file.
+func (l *Logger) Reopen() error {
+	l = l.base()
+	l.rotateMu.Lock()
+	defer l.rotateMu.Unlock()
+	return l.reopen()
+}
+
+func (l *Logger) reopen() error {
+	fileName := l.path()
+	if fileName == "" {
+		return nil
+	}
+
+	newFd, err := l.openOutput(fileName)
+	if nil != err {
+		l.Error("reopen fail: %s", err.Error())
+		return err
+	}
+	l.swapOutput(newFd)
+	return nil
+}
+
+// startWatch runs the External mode loop reopening the file once its path
+// no longer refers to the open fd.
+func (l *Logger) startWatch() (stop func(), err error) {
+	interval := l.rotateCfg.WatchInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ch, stop := l.newLoop()
+
+	l.runLoop(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ch:
+				return
+			case <-ticker.C:
+			}
+			if l.fileReplaced() {
+				l.Reopen()
+			}
+		}
+	})
+	return stop, nil
+}
+
+// fileReplaced reports whether the output file was renamed or removed.
+func (l *Logger) fileReplaced() bool {
+	fd, ok := l.Writer().(*os.File)
+	if !ok {
+		return false
+	}
+	cur, err := fd.Stat()
+	if nil != err {
+		return false
+	}
+	fi, err := os.Stat(l.path())
+	if nil != err {
+		return os.IsNotExist(err)
+	}
+	return !os.SameFile(cur, fi)
+}
@@ -0,0 +1,54 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExternalRotation(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := &RotateConfig{External: true, WatchInterval: 10 * time.Millisecond}
+	logger := New(f, "", 0, LevelDebug, rc)
+	if _, err = logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Stop()
+
+	logger.Info("before")
+	// logrotate with "create": rename away and create a new file
+	if err = os.Rename(logFile, logFile+".1"); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(logFile, nil, 0644)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logger.fileReplaced() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	logger.Info("after")
+
+	rotated, _ := ioutil.ReadFile(logFile + ".1")
+	current, _ := ioutil.ReadFile(logFile)
+	if string(rotated) != "[Info] before\n" {
+		t.Errorf("rotated file: %q", rotated)
+	}
+	if string(current) != "[Info] after\n" {
+		t.Errorf("current file: %q", current)
+	}
+
+	// Rotate must not rename on its own in External mode
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(logFile + "*"); len(files) != 2 {
+		t.Errorf("unexpected files: %v", files)
+	}
+}
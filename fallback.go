@@ -0,0 +1,39 @@
+package rotatelog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// primaryRetryEvery is how long records stay on the fallback before the
+// output is tried again.
+var primaryRetryEvery = time.Second
+
+// writeFailed is called with l.mu held when writing p to the output failed
+// with err. After FallbackAfter failures in a row p and the next records go
+// to the fallback writer.
+func (l *Logger) writeFailed(now time.Time, p []byte, err error) (int, error) {
+	if l.FallbackAfter <= 0 {
+		return 0, err
+	}
+	l.failures++
+	if l.failures < l.FallbackAfter && l.fallbackSince.IsZero() {
+		return 0, err
+	}
+	if l.fallbackSince.IsZero() {
+		l.fallbackSince = now
+		l.fallbackNotice = fmt.Sprintf("output failing (%v), writing to the fallback", err)
+		l.fallbackLevel = LevelCritical
+	}
+	l.primaryRetry = now.Add(primaryRetryEvery)
+	return l.fallback().Write(p)
+}
+
+func (l *Logger) fallback() io.Writer {
+	if l.Fallback != nil {
+		return l.Fallback
+	}
+	return os.Stderr
+}
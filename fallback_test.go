@@ -0,0 +1,102 @@
+package rotatelog
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyWriter fails while broken is set.
+type flakyWriter struct {
+	broken bool
+	buf    bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.broken {
+		return 0, errors.New("device gone")
+	}
+	return w.buf.Write(p)
+}
+
+func TestFallback(t *testing.T) {
+	defer func(d time.Duration) { primaryRetryEvery = d }(primaryRetryEvery)
+	primaryRetryEvery = 50 * time.Millisecond
+
+	var (
+		out      = &flakyWriter{broken: true}
+		fallback bytes.Buffer
+	)
+	logger := New(out, "", 0, LevelDebug, nil)
+	logger.FallbackAfter, logger.Fallback = 2, &fallback
+
+	logger.Info("lost")
+	if fallback.Len() != 0 {
+		t.Fatalf("fallback engaged after one failure: %q", fallback.String())
+	}
+	logger.Info("saved")
+	logger.Info("also saved")
+	want := "[Info] saved\n[Critical] output failing (device gone), writing to the fallback\n[Info] also saved\n"
+	if fallback.String() != want {
+		t.Fatalf("fallback got %q, want %q", fallback.String(), want)
+	}
+
+	out.broken = false
+	logger.Info("still on fallback")
+	time.Sleep(primaryRetryEvery)
+	logger.Info("back")
+	got := out.buf.String()
+	if !strings.HasPrefix(got, "[Info] back\n[Warning] output recovered after ") || !strings.HasSuffix(got, " on the fallback\n") {
+		t.Errorf("output got %q", got)
+	}
+	if !bytes.HasSuffix(fallback.Bytes(), []byte("[Info] still on fallback\n")) {
+		t.Errorf("fallback got %q", fallback.String())
+	}
+}
+
+// levelRecorder is a LevelWriter keeping "level msg" lines.
+type levelRecorder struct {
+	lines  []string
+	broken bool
+}
+
+func (r *levelRecorder) WriteLevel(level Level, msg string) error {
+	if r.broken {
+		return errors.New("event log gone")
+	}
+	r.lines = append(r.lines, level.Tag()+" "+msg)
+	return nil
+}
+
+func (r *levelRecorder) Write(p []byte) (int, error) {
+	return len(p), r.WriteLevel(LevelInfo, string(p))
+}
+
+func TestLevelWriterOptions(t *testing.T) {
+	var (
+		out      = &levelRecorder{}
+		mirror   bytes.Buffer
+		fallback bytes.Buffer
+	)
+	logger := New(out, "", 0, LevelDebug, nil)
+	logger.MaxMessageBytes = 16
+	logger.Mirror = New(&mirror, "", 0, LevelDebug, nil)
+	logger.FallbackAfter, logger.Fallback = 1, &fallback
+
+	logger.Warning("short")
+	logger.Error("a message well over the limit")
+	if want := []string{"[Warning] short\n", "[Error] a message well \n"}; strings.Join(out.lines, "|") != strings.Join(want, "|") {
+		t.Errorf("level writer got %q, want %q", out.lines, want)
+	}
+	if want := "short\na message well \n"; mirror.String() != want {
+		t.Errorf("mirror got %q, want %q", mirror.String(), want)
+	}
+
+	out.broken = true
+	logger.Info("saved")
+	if !strings.HasPrefix(fallback.String(), "saved\n") {
+		t.Errorf("fallback got %q", fallback.String())
+	}
+}
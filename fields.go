@@ -0,0 +1,114 @@
+package rotatelog
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Field is a key and value logged along a message by Logw and the like.
+// Text records get the fields as key=value pairs after the message,
+// FormatLogfmt records as keys of their own.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func Str(key, value string) Field {
+	return Field{key, value}
+}
+
+func Int(key string, value int) Field {
+	return Field{key, value}
+}
+
+// Duration renders d as d.String() in text and as milliseconds in logfmt,
+// e.g. latency=1.5.
+func Duration(key string, d time.Duration) Field {
+	return Field{key, d}
+}
+
+// Time renders t in RFC 3339 with nanoseconds.
+func Time(key string, t time.Time) Field {
+	return Field{key, t}
+}
+
+// Err is the field "error" holding err, <nil> if there is none.
+func Err(err error) Field {
+	return Field{"error", err}
+}
+
+// text renders the value for text records.
+func (f Field) text() string {
+	switch v := f.Value.(type) {
+	case string:
+		return v
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprint(f.Value)
+}
+
+// structured renders the value for FormatLogfmt records.
+func (f Field) structured() string {
+	if d, ok := f.Value.(time.Duration); ok {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+	}
+	return f.text()
+}
+
+// textFields renders fields as " k=v" pairs, quoted as in logfmt.
+func textFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var b []byte
+	for _, f := range fields {
+		b = appendLogfmt(b, f.Key, f.text())
+	}
+	return " " + string(b)
+}
+
+// logw is log for a message with fields.
+func (l *Logger) logw(level Level, msg string, fields []Field) {
+	if !l.enabled(level) {
+		return
+	}
+	if l.StackDepth > 0 && level >= l.StackLevel {
+		msg += stack(1, l.StackDepth)
+	}
+	l.outputFields(4, level, msg, fields)
+}
+
+// Logw logs msg at level with fields.
+func (l *Logger) Logw(level Level, msg string, fields ...Field) {
+	l.logw(level, msg, fields)
+}
+
+func (l *Logger) Debugw(msg string, fields ...Field) {
+	l.logw(LevelDebug, msg, fields)
+}
+
+func (l *Logger) Infow(msg string, fields ...Field) {
+	l.logw(LevelInfo, msg, fields)
+}
+
+func (l *Logger) Noticew(msg string, fields ...Field) {
+	l.logw(LevelNotice, msg, fields)
+}
+
+func (l *Logger) Warningw(msg string, fields ...Field) {
+	l.logw(LevelWarning, msg, fields)
+}
+
+func (l *Logger) Errorw(msg string, fields ...Field) {
+	l.logw(LevelError, msg, fields)
+}
+
+func (l *Logger) Criticalw(msg string, fields ...Field) {
+	l.logw(LevelCritical, msg, fields)
+}
@@ -0,0 +1,54 @@
+package rotatelog
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	fields := []Field{
+		Duration("latency", 1500*time.Microsecond),
+		Err(errors.New("conn reset")),
+		Str("path", "/a b"),
+		Int("status", 502),
+		Time("at", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)),
+	}
+
+	logger.Warningw("request failed", fields...)
+	if want := `[Warning] request failed latency=1.5ms error="conn reset" path="/a b" status=502 at=2026-10-16T12:00:00Z` + "\n"; buf.String() != want {
+		t.Errorf("text got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	logger.Format = FormatLogfmt
+	logger.Warningw("request failed", fields...)
+	re := regexp.MustCompile(`^ts=\S+ level=warning msg="request failed" latency=1.5 error="conn reset" path="/a b" status=502 at=2026-10-16T12:00:00Z\n$`)
+	if !re.MatchString(buf.String()) {
+		t.Errorf("logfmt got %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.Format = FormatText
+	logger.SetFilter(func(level Level, msg string) bool { return !strings.Contains(msg, "status=200") })
+	logger.Infow("served", Int("status", 200), Err(nil))
+	logger.Infow("served", Int("status", 404), Err(nil))
+	if want := "[Info] served status=404 error=<nil>\n"; buf.String() != want {
+		t.Errorf("filtered got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFieldsCaller(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", log.Lshortfile, LevelDebug, nil)
+	logger.Infow("here", Int("n", 1))
+	if !strings.HasPrefix(buf.String(), "fields_test.go:") {
+		t.Errorf("got %q, want the caller of Infow", buf.String())
+	}
+}
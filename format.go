@@ -0,0 +1,193 @@
+package rotatelog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Format selects how records are rendered.
+type Format int
+
+const (
+	// FormatText is the stdlib log layout with a level tag.
+	FormatText Format = iota
+	// FormatBinary writes length-prefixed frames: a big-endian uint32 frame
+	// length, an int64 unix nanosecond timestamp, the level byte and the
+	// message. Use DecodeStream to render them as text.
+	FormatBinary
+	// FormatLogfmt writes key=value lines, see appendLogfmt.
+	FormatLogfmt
+)
+
+const (
+	binaryHeaderLen = 8 + 1
+
+	// maxBinaryMessage bounds a frame, longer messages are cut when written
+	// and longer frames rejected when decoded.
+	maxBinaryMessage = 16 << 20
+)
+
+var errInvalidFrame = errors.New("invalid binary log frame")
+
+func (l *Logger) writeBinary(level Level, s string) error {
+	_, err := l.writeRaw(binaryFrame(level, s))
+	return err
+}
+
+// binaryFrame encodes a FormatBinary record.
+func binaryFrame(level Level, s string) []byte {
+	if len(s) > maxBinaryMessage {
+		s = s[:maxBinaryMessage]
+	}
+	frame := make([]byte, 4+binaryHeaderLen+len(s))
+	binary.BigEndian.PutUint32(frame, uint32(binaryHeaderLen+len(s)))
+	binary.BigEndian.PutUint64(frame[4:], uint64(time.Now().UnixNano()))
+	frame[12] = byte(level)
+	copy(frame[13:], s)
+	return frame
+}
+
+// DecodeStream renders the FormatBinary records read from r as text lines
+// on w until r is exhausted.
+func DecodeStream(r io.Reader, w io.Writer) error {
+	var (
+		br   = bufio.NewReader(r)
+		bw   = bufio.NewWriter(w)
+		size [4]byte
+		buf  []byte
+	)
+	for {
+		if _, err := io.ReadFull(br, size[:]); err != nil {
+			if err == io.EOF {
+				return bw.Flush()
+			}
+			return errInvalidFrame
+		}
+		n := int(binary.BigEndian.Uint32(size[:]))
+		if n < binaryHeaderLen || n > binaryHeaderLen+maxBinaryMessage {
+			return errInvalidFrame
+		}
+		if cap(buf) < n {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return errInvalidFrame
+		}
+
+		ts := time.Unix(0, int64(binary.BigEndian.Uint64(buf)))
+		fmt.Fprintf(bw, "%s %s%s%s\n", ts.Format("2006/01/02 15:04:05.000000"), Level(buf[8]).Tag(), tagSep, buf[binaryHeaderLen:])
+	}
+}
+
+// formatLine renders s with the LineFormat template, whose placeholders
+// are {time}, {level}, {caller}, {prefix} and {message}. Time and caller
+// follow the log flags, e.g. Lmicroseconds, LUTC and Lshortfile.
+func (l *Logger) formatLine(calldepth int, level Level, s string) string {
+	var (
+		flag   = l.Flags()
+		now    = l.now(flag)
+		caller string
+	)
+	if strings.Contains(l.LineFormat, "{caller}") {
+		caller = callerOf(calldepth, flag)
+	}
+
+	line := strings.NewReplacer(
+		"{time}", now.Format(timeLayout(flag)),
+		"{level}", l.levelTag(level),
+		"{caller}", caller,
+		"{prefix}", l.Prefix(),
+		"{message}", s,
+	).Replace(l.LineFormat)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	return line
+}
+
+// formatText renders s in the stdlib layout with the time in TimeZone,
+// which log.Logger can't do.
+func (l *Logger) formatText(calldepth int, level Level, s string) string {
+	b := append(l.appendHeader(make([]byte, 0, 64+len(s)), calldepth+1, level), s...)
+	if !strings.HasSuffix(s, "\n") {
+		b = append(b, '\n')
+	}
+	return string(b)
+}
+
+// appendHeader appends what precedes the message of a text record to b:
+// the prefix, time and caller as the flags select and the level tag.
+func (l *Logger) appendHeader(b []byte, calldepth int, level Level) []byte {
+	var (
+		flag   = l.Flags()
+		prefix = l.Prefix()
+	)
+	if flag&log.Lmsgprefix == 0 {
+		b = append(b, prefix...)
+	}
+	if flag&(log.Ldate|log.Ltime|log.Lmicroseconds) != 0 {
+		b = l.now(flag).AppendFormat(b, timeLayout(flag))
+		b = append(b, ' ')
+	}
+	if flag&(log.Lshortfile|log.Llongfile) != 0 {
+		b = append(b, callerOf(calldepth, flag)...)
+		b = append(b, ": "...)
+	}
+	if flag&log.Lmsgprefix != 0 {
+		b = append(b, prefix...)
+	}
+	b = append(b, l.levelTag(level)...)
+	return append(b, tagSep...)
+}
+
+// now returns the record time in TimeZone, else in UTC with LUTC.
+func (l *Logger) now(flag int) time.Time {
+	now := time.Now()
+	if l.TimeZone != nil {
+		return now.In(l.TimeZone)
+	}
+	if flag&log.LUTC != 0 {
+		return now.UTC()
+	}
+	return now
+}
+
+// callerOf returns the file:line calldepth frames above its caller, the
+// file shortened with Lshortfile.
+func callerOf(calldepth int, flag int) string {
+	_, file, line, ok := runtime.Caller(calldepth + 1)
+	if !ok {
+		file, line = "???", 0
+	}
+	if flag&log.Lshortfile != 0 {
+		file = filepath.Base(file)
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// timeLayout returns the date and time layout of the log flags, the
+// stdlib default if they select neither.
+func timeLayout(flag int) string {
+	var parts []string
+	if flag&log.Ldate != 0 {
+		parts = append(parts, "2006/01/02")
+	}
+	if flag&log.Lmicroseconds != 0 {
+		parts = append(parts, "15:04:05.000000")
+	} else if flag&log.Ltime != 0 {
+		parts = append(parts, "15:04:05")
+	}
+	if len(parts) == 0 {
+		return "2006/01/02 15:04:05"
+	}
+	return strings.Join(parts, " ")
+}
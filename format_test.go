@@ -0,0 +1,123 @@
+package rotatelog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.Format = FormatBinary
+
+	start := time.Now()
+	logger.Debug("first %d", 1)
+	logger.Warning("second")
+	logger.Critical("")
+
+	var out bytes.Buffer
+	if err := DecodeStream(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{"[Debug] first 1", "[Warning] second", "[Critical] "}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", out.String())
+	}
+	for i, line := range lines {
+		const layout = "2006/01/02 15:04:05.000000"
+		ts, err := time.ParseInLocation(layout, line[:len(layout)], time.Local)
+		if err != nil || ts.Before(start.Truncate(time.Microsecond)) || ts.After(time.Now()) {
+			t.Errorf("line %d: bad timestamp %q: %v", i, line, err)
+		}
+		if msg := line[len(layout)+1:]; msg != want[i] {
+			t.Errorf("line %d: got %q, want %q", i, msg, want[i])
+		}
+	}
+
+	if err := DecodeStream(strings.NewReader("\x00\x00\x00\x20short"), &out); err == nil {
+		t.Error("truncated frame decoded")
+	}
+}
+
+func TestDecodeStreamOversizedFrame(t *testing.T) {
+	frame := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if err := DecodeStream(bytes.NewReader(frame), ioutil.Discard); err != errInvalidFrame {
+		t.Errorf("got %v, want errInvalidFrame", err)
+	}
+}
+
+func TestLineFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "app ", log.Ltime|log.Lshortfile, LevelDebug, nil)
+	logger.LineFormat = "{time} | {message} | {level} {prefix}{caller}"
+	logger.Warning("disk at %d%%", 91)
+
+	fields := strings.Split(strings.TrimSuffix(buf.String(), "\n"), " | ")
+	if len(fields) != 3 {
+		t.Fatalf("got %q", buf.String())
+	}
+	if _, err := time.Parse("15:04:05", fields[0]); err != nil {
+		t.Errorf("time field %q: %v", fields[0], err)
+	}
+	if fields[1] != "disk at 91%" {
+		t.Errorf("message field %q", fields[1])
+	}
+	if !strings.HasPrefix(fields[2], "[Warning] app format_test.go:") {
+		t.Errorf("level and caller field %q", fields[2])
+	}
+
+	buf.Reset()
+	logger.LineFormat = ""
+	logger.SetFlags(0)
+	logger.Info("default")
+	if buf.String() != "app [Info] default\n" {
+		t.Errorf("default layout = %q", buf.String())
+	}
+}
+
+func TestTimeZone(t *testing.T) {
+	zone, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	var buf bytes.Buffer
+	logger := New(&buf, "app: ", log.Ldate|log.Ltime|log.LUTC|log.Lshortfile, LevelDebug, nil)
+	logger.TimeZone = zone
+
+	before := time.Now().In(zone).Truncate(time.Second)
+	logger.Info("hello")
+	after := time.Now().In(zone)
+
+	var day, clock string
+	if _, err = fmt.Sscanf(buf.String(), "app: %s %s format_test.go:", &day, &clock); err != nil {
+		t.Fatalf("unexpected record %q: %v", buf.String(), err)
+	}
+	ts, err := time.ParseInLocation("2006/01/02 15:04:05", day+" "+clock, zone)
+	if err != nil || ts.Before(before) || ts.After(after) {
+		t.Errorf("record time %q not in New York time between %v and %v", day+" "+clock, before, after)
+	}
+	if !strings.HasSuffix(buf.String(), ": [Info] hello\n") {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestFormatTextMatchesStdlib(t *testing.T) {
+	for _, flag := range []int{0, log.Lshortfile, log.Lshortfile | log.Lmsgprefix} {
+		var got [2]bytes.Buffer
+		for i, zone := range []*time.Location{nil, time.Local} {
+			logger := New(&got[i], "app: ", flag, LevelDebug, nil)
+			logger.TimeZone = zone
+			logger.Info("same")
+		}
+		if got[1].String() != got[0].String() {
+			t.Errorf("flag %d: got %q, want %q", flag, got[1].String(), got[0].String())
+		}
+	}
+}
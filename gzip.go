@@ -0,0 +1,80 @@
+package rotatelog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+)
+
+type gzipFile struct {
+	mu sync.Mutex
+	f  *os.File
+	zw *gzip.Writer
+
+	flushEvery int // flush after that many writes, 0 for never
+	writes     int // since the last flush
+}
+
+// OpenGzip opens path for appending a gzip stream. Data reaches the file as
+// gzip blocks fill up, Flush forces the pending data out and Close ends the
+// member. An existing file gets a new member, which gzip readers
+// concatenate.
+func OpenGzip(path string) (io.WriteCloser, error) {
+	return openGzip(path, 0)
+}
+
+// openGzip is OpenGzip flushing after every flushEvery writes, each
+// record being one.
+func openGzip(path string, flushEvery int) (*gzipFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if nil != err {
+		return nil, err
+	}
+	return &gzipFile{f: f, zw: gzip.NewWriter(f), flushEvery: flushEvery}, nil
+}
+
+func (g *gzipFile) Write(p []byte) (n int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if n, err = g.zw.Write(p); nil != err || g.flushEvery <= 0 {
+		return
+	}
+	if g.writes++; g.writes >= g.flushEvery {
+		g.writes = 0
+		err = g.zw.Flush()
+	}
+	return
+}
+
+// Flush writes the pending compressed data to the file.
+func (g *gzipFile) Flush() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writes = 0
+	return g.zw.Flush()
+}
+
+// Sync flushes pending data and commits the file to stable storage.
+func (g *gzipFile) Sync() error {
+	if err := g.Flush(); nil != err {
+		return err
+	}
+	return g.f.Sync()
+}
+
+func (g *gzipFile) Name() string {
+	return g.f.Name()
+}
+
+func (g *gzipFile) closeBeforeExit() {}
+
+func (g *gzipFile) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	err := g.zw.Close()
+	if cerr := g.f.Close(); nil == err {
+		err = cerr
+	}
+	return err
+}
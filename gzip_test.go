@@ -0,0 +1,207 @@
+package rotatelog
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return string(data)
+}
+
+func writeGzip(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	io.WriteString(zw, data)
+	if err = zw.Close(); err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLiveGzip(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log.gz")
+	w, err := OpenGzip(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, LiveGzip: true}
+	logger := New(w, "", 0, LevelDebug, rc)
+	logger.Info("first file")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("second file")
+	logger.WaitPending()
+	if err = logger.Writer().(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(filepath.Dir(logFile), "app.log.*.gz"))
+	if len(archives) != 1 {
+		t.Fatalf("want one archive, got %v", archives)
+	}
+	if got := readGzip(t, archives[0]); got != "[Info] first file\n" {
+		t.Errorf("archive content %q", got)
+	}
+	if got := readGzip(t, logFile); got != "[Info] second file\n" {
+		t.Errorf("live content %q", got)
+	}
+}
+
+func TestCompressKeepsMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.202610161200")
+	if err := ioutil.WriteFile(path, []byte("archived\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2026, 10, 16, 12, 59, 30, 0, time.Local)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour})
+	if err := logger.compress(path); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.Name != "app.log.202610161200" {
+		t.Errorf("gzip header name %q", zr.Name)
+	}
+	if d := zr.ModTime.Sub(mtime); d < -time.Second || d > time.Second {
+		t.Errorf("gzip header mtime %v, want %v", zr.ModTime, mtime)
+	}
+	if fi, err := f.Stat(); err != nil || !fi.ModTime().Equal(mtime) {
+		t.Errorf(".gz mtime %v, want %v", fi.ModTime(), mtime)
+	}
+}
+
+func TestLiveGzipFlush(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log.gz")
+	w, err := OpenGzip(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 5, LiveGzip: true, CheckInterval: 10 * time.Millisecond}
+	logger := New(w, "", 0, LevelDebug, rc)
+	if _, err = logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Stop()
+	logger.Info("flushed")
+
+	// read the unfinished member as a crash would leave it
+	var got []byte
+	for deadline := time.Now().Add(time.Second); len(got) == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		f, err := os.Open(logFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zr, err := gzip.NewReader(f); err == nil {
+			got, _ = ioutil.ReadAll(zr)
+		}
+		f.Close()
+	}
+	if string(got) != "[Info] flushed\n" {
+		t.Errorf("got %q before close", got)
+	}
+}
+
+func TestGzipFlushEvery(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log.gz")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, LiveGzip: true, GzipFlushEvery: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	for i := 1; i <= 5; i++ {
+		logger.Info("record %d", i)
+	}
+
+	// the member is unfinished, read what the flushes wrote out
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadAll(zr)
+	if want := "[Info] record 1\n[Info] record 2\n[Info] record 3\n[Info] record 4\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log.gz")
+	w, err := OpenGzip(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// without LiveGzip only SyncInterval flushes the member
+	logger := New(w, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, SyncInterval: 10 * time.Millisecond})
+	if _, err = logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("synced")
+
+	var got []byte
+	for deadline := time.Now().Add(time.Second); len(got) == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		f, err := os.Open(logFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zr, err := gzip.NewReader(f); err == nil {
+			got, _ = ioutil.ReadAll(zr)
+		}
+		f.Close()
+	}
+	if string(got) != "[Info] synced\n" {
+		t.Errorf("got %q before close", got)
+	}
+
+	logger.Stop()
+	if _, ok := logger.NextRotation(); ok {
+		t.Error("the loop still runs after Stop")
+	}
+}
@@ -0,0 +1,94 @@
+package rotatelog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// User callbacks run through the wrappers below, a panic in one is logged
+// as a Warning and the callback's safe default is used instead.
+
+// recoverHook must be deferred directly by the wrapper calling hook.
+func (l *Logger) recoverHook(hook string) {
+	if r := recover(); r != nil && l.enabled(LevelWarning) {
+		l.emit(3, LevelWarning, fmt.Sprintf("%s panic: %v", hook, r), nil)
+	}
+}
+
+// filterKeeps keeps the record if f panics.
+func (l *Logger) filterKeeps(f Filter, level Level, s string) (keep bool) {
+	keep = true
+	defer l.recoverHook("Filter")
+	return f(level, s)
+}
+
+// shouldRotate skips the rotation if ShouldRotate panics.
+func (l *Logger) shouldRotate() (rotate bool) {
+	defer l.recoverHook("ShouldRotate")
+	return l.rotateCfg.ShouldRotate()
+}
+
+// beforeDelete keeps the file if BeforeDelete panics.
+func (l *Logger) beforeDelete(path string) (deleteOK bool) {
+	defer l.recoverHook("BeforeDelete")
+	return l.rotateCfg.BeforeDelete(path)
+}
+
+// selectRetention removes nothing if Retention panics.
+func (l *Logger) selectRetention(archives []ArchiveInfo, now time.Time) (delete []string) {
+	if l.rotateCfg.Retention == nil {
+		return l.agePolicy().Select(archives, now)
+	}
+	defer l.recoverHook("Retention")
+	return l.rotateCfg.Retention.Select(archives, now)
+}
+
+// handleError ignores a panic in ErrorHandler.
+func (l *Logger) handleError(err error) {
+	if l.ErrorHandler == nil {
+		return
+	}
+	defer l.recoverHook("ErrorHandler")
+	l.ErrorHandler(err)
+}
+
+// suffixTime uses now if SuffixTimeFunc is unset or panics.
+func (l *Logger) suffixTime(now time.Time) (t time.Time) {
+	if l.rotateCfg.SuffixTimeFunc == nil {
+		return now
+	}
+	t = now
+	defer l.recoverHook("SuffixTimeFunc")
+	return l.rotateCfg.SuffixTimeFunc()
+}
+
+var errOpenFuncPanic = errors.New("OpenFunc panicked")
+
+// openFunc fails the open if OpenFunc panics.
+func (l *Logger) openFunc(path string) (w io.WriteCloser, err error) {
+	err = errOpenFuncPanic
+	defer l.recoverHook("OpenFunc")
+	return l.rotateCfg.OpenFunc(path)
+}
+
+var errArchiveSinkPanic = errors.New("ArchiveSink panicked")
+
+// archiveSink fails the shipping if ArchiveSink panics.
+func (l *Logger) archiveSink(name string) (w io.WriteCloser, err error) {
+	err = errArchiveSinkPanic
+	defer l.recoverHook("ArchiveSink")
+	return l.rotateCfg.ArchiveSink(name)
+}
+
+// sinkFactory fails the writer if SinkFactory panics. The panic isn't
+// logged, as the record would ask SinkFactory again.
+func (l *Logger) sinkFactory(level Level) (w io.Writer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w, err = nil, fmt.Errorf("SinkFactory panic: %v", r)
+		}
+	}()
+	return l.SinkFactory(level)
+}
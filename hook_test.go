@@ -0,0 +1,64 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookPanic(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.SetFilter(func(level Level, msg string) bool {
+		if msg == "boom" {
+			panic("bad filter")
+		}
+		return true
+	})
+	logger.Info("boom")
+	logger.Info("after")
+	if want := "[Warning] Filter panic: bad filter\n[Info] boom\n[Info] after\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	logger.SetFilter(nil)
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.rotateCfg = &RotateConfig{
+		Duration:     time.Minute,
+		MaxBackups:   1,
+		ShouldRotate: func() bool { panic("bad check") },
+		BeforeDelete: func(string) bool { panic("bad veto") },
+		OpenFunc:     func(string) (io.WriteCloser, error) { panic("bad open") },
+	}
+	logger.SetOutput(f)
+
+	if logger.shouldRotate() {
+		t.Error("a panicking ShouldRotate should not rotate")
+	}
+	old := logFile + ".200601021504"
+	ioutil.WriteFile(old, nil, 0644)
+	if _, ok := logger.removeFile(old); ok {
+		t.Error("a panicking BeforeDelete should keep the file")
+	}
+	if err = logger.Rotate(); err != errOpenFuncPanic {
+		t.Errorf("Rotate with a panicking OpenFunc: %v", err)
+	}
+
+	logger.Info("still logging")
+	b, _ := ioutil.ReadFile(logFile)
+	for _, want := range []string{"ShouldRotate panic: bad check", "BeforeDelete panic: bad veto", "OpenFunc panic: bad open", "[Info] still logging"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("missing %q in %q", want, b)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package rotatelog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type lazyFile struct {
+	mu     sync.Mutex
+	path   string
+	idle   time.Duration
+	f      *os.File // nil while released
+	timer  *time.Timer
+	closed bool
+}
+
+// OpenLazy returns a writer appending to path that holds no descriptor
+// while unused: the file is opened, and created, on the first write and
+// closed again once idle for the given duration.
+func OpenLazy(path string, idle time.Duration) (io.WriteCloser, error) {
+	if _, err := os.Stat(filepath.Dir(path)); nil != err {
+		return nil, err
+	}
+	return &lazyFile{path: path, idle: idle}, nil
+}
+
+func (z *lazyFile) Write(p []byte) (int, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.closed {
+		return 0, os.ErrClosed
+	}
+	if z.f == nil {
+		f, err := os.OpenFile(z.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if nil != err {
+			return 0, err
+		}
+		z.f = f
+	}
+	if z.timer == nil {
+		z.timer = time.AfterFunc(z.idle, z.release)
+	} else {
+		z.timer.Reset(z.idle)
+	}
+	return z.f.Write(p)
+}
+
+// release closes the file after the idle timeout.
+func (z *lazyFile) release() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.f != nil {
+		z.f.Close()
+		z.f = nil
+	}
+}
+
+// Sync commits the file to stable storage if it is open.
+func (z *lazyFile) Sync() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.f == nil {
+		return nil
+	}
+	return z.f.Sync()
+}
+
+func (z *lazyFile) Name() string {
+	return z.path
+}
+
+func (z *lazyFile) Close() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.closed {
+		return os.ErrClosed
+	}
+	z.closed = true
+	if z.timer != nil {
+		z.timer.Stop()
+	}
+	if z.f == nil {
+		return nil
+	}
+	err := z.f.Close()
+	z.f = nil
+	return err
+}
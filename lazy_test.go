@@ -0,0 +1,68 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func (z *lazyFile) isOpen() bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.f != nil
+}
+
+func TestOpenLazy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := OpenLazy(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	z := w.(*lazyFile)
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file created before the first write: %v", err)
+	}
+
+	logger := New(w, "", 0, LevelDebug, nil)
+	logger.Info("one")
+	if !z.isOpen() {
+		t.Fatal("file not open after a write")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if z.isOpen() {
+		t.Fatal("file still open after the idle timeout")
+	}
+
+	logger.Info("two")
+	if data, _ := ioutil.ReadFile(path); string(data) != "[Info] one\n[Info] two\n" {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestRotateLazySkipsUnwritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := OpenLazy(path, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := New(w, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, LazyIdle: time.Minute})
+	logger.Info("one")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	defer logger.Writer().(*lazyFile).Close()
+
+	if archives, _ := filepath.Glob(path + ".*"); len(archives) != 1 {
+		t.Errorf("want one archive, got %q", archives)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("new file created before a write: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package rotatelog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+)
+
+// levelSink returns the SinkFactory writer of level, obtained on first
+// use, or nil to use the output. Factory errors go to the ErrorHandler
+// and the factory is asked again for the next record.
+func (l *Logger) levelSink(level Level) io.Writer {
+	root := l.base()
+	root.sinkMu.Lock()
+	defer root.sinkMu.Unlock()
+	if w, ok := root.sinks[level]; ok {
+		return w
+	}
+	w, err := l.sinkFactory(level)
+	if nil != err || w == nil {
+		if nil == err {
+			err = fmt.Errorf("SinkFactory returned no writer for %s", level.Tag())
+		}
+		l.handleError(err)
+		return nil
+	}
+	if root.sinks == nil {
+		root.sinks = make(map[Level]io.Writer)
+	}
+	root.sinks[level] = w
+	return w
+}
+
+// writeSink formats a record as emit would and writes it to w.
+func (l *Logger) writeSink(w io.Writer, calldepth int, level Level, s string, fields []Field) error {
+	var buf bytes.Buffer
+	l.appendRecord(&buf, log.New(&buf, l.Prefix(), l.Flags()), calldepth+1, level, s, fields)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// closeSinks closes the SinkFactory writers that are io.Closers.
+func (l *Logger) closeSinks() (err error) {
+	l.sinkMu.Lock()
+	sinks := l.sinks
+	l.sinks = nil
+	l.sinkMu.Unlock()
+	for _, w := range sinks {
+		if c, ok := w.(io.Closer); ok {
+			if cerr := c.Close(); nil == err {
+				err = cerr
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package rotatelog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestSinkFactory(t *testing.T) {
+	var (
+		primary bytes.Buffer
+		sinks   = map[Level]*bytes.Buffer{}
+		calls   = map[Level]int{}
+		errs    []error
+	)
+	logger := New(&primary, "", 0, LevelDebug, nil)
+	logger.ErrorHandler = func(err error) { errs = append(errs, err) }
+	logger.SinkFactory = func(level Level) (io.Writer, error) {
+		calls[level]++
+		if level == LevelWarning {
+			return nil, errors.New("no topic")
+		}
+		sinks[level] = new(bytes.Buffer)
+		return sinks[level], nil
+	}
+
+	logger.Info("one")
+	logger.Error("failed")
+	logger.Info("two")
+	logger.Warning("fallback")
+	logger.Named("db").Info("three")
+	if _, err := logger.WriteBatch(LevelError, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := sinks[LevelInfo].String(); got != "[Info] one\n[Info] two\n[Info] [db] three\n" {
+		t.Errorf("info sink has %q", got)
+	}
+	if got := sinks[LevelError].String(); got != "[Error] failed\n[Error] a\n[Error] b\n" {
+		t.Errorf("error sink has %q", got)
+	}
+	if got := primary.String(); got != "[Warning] fallback\n" {
+		t.Errorf("primary has %q", got)
+	}
+	if calls[LevelInfo] != 1 || calls[LevelError] != 1 {
+		t.Errorf("factory calls %v, want one per level", calls)
+	}
+	if len(errs) != 1 {
+		t.Errorf("errors %v", errs)
+	}
+}
+
+func TestSinkFactoryWrite(t *testing.T) {
+	var primary, sink bytes.Buffer
+	logger := New(&primary, "", 0, LevelDebug, nil)
+	logger.SinkFactory = func(level Level) (io.Writer, error) { return &sink, nil }
+	logger.Write(LevelError, []byte("raw"))
+	if sink.String() != "[Error] raw\n" || primary.Len() != 0 {
+		t.Errorf("sink has %q, primary %q", sink.String(), primary.String())
+	}
+}
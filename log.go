@@ -1,15 +1,24 @@
 package rotatelog
 
 import (
+	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+	"unsafe"
 )
 
 // Level describes the level of a log message.
@@ -25,14 +34,16 @@ const (
 )
 
 const (
-	tagDebug    = "[Debug] "
-	tagInfo     = "[Info] "
-	tagNotice   = "[Notice] "
-	tagWarning  = "[Warning] "
-	tagError    = "[Error] "
-	tagCritical = "[Critical] "
+	tagDebug    = "[Debug]"
+	tagInfo     = "[Info]"
+	tagNotice   = "[Notice]"
+	tagWarning  = "[Warning]"
+	tagError    = "[Error]"
+	tagCritical = "[Critical]"
+	tagSep      = " "
 	formatMin   = "200601021504"
 	formatSec   = "20060102150405"
+	formatNano  = "20060102150405,000000000"
 )
 
 var (
@@ -56,6 +67,9 @@ var (
 	}
 
 	errInvalidRotateConfig = errors.New("invalid log rotate config")
+
+	// diskFree reports the free bytes of the volume holding dir, false if unknown.
+	diskFree = freeSpace
 )
 
 func NewLevel(name string) Level {
@@ -65,112 +79,1284 @@ func NewLevel(name string) Level {
 	return LevelError
 }
 
-// String returns the string representation of the log level
-func (l Level) String() string {
+// Tag returns the bracketed tag of the log level, e.g. "[Info]".
+func (l Level) Tag() string {
 	if name, ok := levelTags[l]; ok {
 		return name
 	}
-	return "[Unknown] "
+	return "[Unknown]"
+}
+
+// String returns the string representation of the log level, the tag
+// followed by the space separating it from the message.
+func (l Level) String() string {
+	return l.Tag() + tagSep
 }
 
 type RotateConfig struct {
-	Rotate   int           // keeped log files count
-	Duration time.Duration // log rotate duration
-	Compress bool
+	MaxBackups int           `json:"max_backups"` // keeped log files count
+	Duration   time.Duration `json:"duration"`    // log rotate duration
+	Compress   bool          `json:"compress"`
+	LocalTime  bool          `json:"local_time"` // align periods of up to a day to local midnight instead of UTC
+
+	// CompressDelay leaves the newest archives, that many, uncompressed
+	// for quick inspection. Older ones are compressed on later rotations.
+	CompressDelay int `json:"compress_delay"`
+
+	// CompressDirect, with Compress, moves the log file aside under a
+	// hidden name and compresses it from there, so the archive only shows
+	// up compressed and whole, name.<suffix>.gz, never as plain or partial
+	// name.<suffix>. Ignored with MergePeriod, LiveGzip, Pipeline and
+	// CompressDelay. A crash mid-way leaves the hidden file, .name.<suffix>.
+	CompressDirect bool `json:"compress_direct"`
+
+	// ContentAddressed, with Compress, stores each compressed archive as
+	// <sha256 of its records>.gz next to it, so identical archives such as
+	// those of idle periods are kept once. The archive names map to those
+	// files in cas.index, which retention reads. Ignored with EncryptKey.
+	ContentAddressed bool `json:"content_addressed"`
+
+	// Deprecated: Rotate is the old name of MaxBackups and is only used
+	// when MaxBackups is not set.
+	Rotate int `json:"rotate"`
+
+	RotateOnStart   bool  `json:"rotate_on_start"`  // StartRotate archives a non-empty log file left by a previous run
+	RotateOnResume  bool  `json:"rotate_on_resume"` // rotate at once on Resume if a rotation was missed while paused
+	TruncateNew     bool  `json:"truncate_new"`     // truncate instead of append when the new log file already exists
+	CarryTailBytes  int   `json:"carry_tail_bytes"` // start the new log file with up to this many whole last lines of the archived one
+	SkipEmpty       bool  `json:"skip_empty"`       // Rotate leaves an empty log file in place, archiving nothing
+	RotationRecord  bool  `json:"rotation_record"`  // write a record naming the archive, its size and sha256 to the new log file
+	SyncMaintenance bool  `json:"sync_maintenance"` // Rotate compresses and cleans up before returning, for short-lived processes
+	Preallocate     int64 `json:"preallocate"`      // reserve this many bytes for a new log file where fallocate is supported
+	MinFreeBytes    int64 `json:"min_free_bytes"`   // remove oldest log files while the volume has less free space
+
+	// CompressOnClose makes Close gzip the log file onto name.gz, as a
+	// member appended to the one an earlier run left. Not with EncryptKey.
+	CompressOnClose bool `json:"compress_on_close"`
+
+	// CleanOnDiskFull removes the oldest archive each time a write fails
+	// for lack of space.
+	CleanOnDiskFull bool `json:"clean_on_disk_full"`
+
+	// MkdirAll creates the missing parent directories of the log file,
+	// with DirMode (default 0755), when it is opened or rotated.
+	MkdirAll bool        `json:"mkdir_all"`
+	DirMode  os.FileMode `json:"dir_mode"`
+
+	// ArchiveMode, if set, is the permission of archives once finished,
+	// compressed and encrypted ones included, e.g. 0600 where the live
+	// file must stay readable to others.
+	ArchiveMode os.FileMode `json:"archive_mode"`
+
+	// External leaves rename and retention to an outside tool such as
+	// logrotate: Rotate only reopens the file and StartRotate watches the
+	// path every WatchInterval (default a second), reopening when replaced.
+	External      bool          `json:"external"`
+	WatchInterval time.Duration `json:"watch_interval"`
+
+	// Collision is the policy for an archive name already taken, MergePeriod
+	// archives are appended to instead.
+	Collision Collision `json:"collision"`
+
+	// NanoSuffix names archives after the rotation time to the nanosecond,
+	// name.20060102150405,000000000, rather than the period start, so
+	// rotations in quick succession, e.g. by ShouldRotate, never share one.
+	NanoSuffix bool `json:"nano_suffix"`
+
+	// MergePeriod, with Compress, appends every archive of the same period
+	// to a single .gz as separate gzip members instead of overwriting it.
+	MergePeriod bool `json:"merge_period"`
+
+	// CompressCommand, if set, compresses archives in place of gzip: the
+	// command, an argv such as {"xz", "-9"}, gets the archive on stdin and
+	// its stdout is saved as archive.CompressExt. MergePeriod still uses
+	// gzip.
+	CompressCommand []string `json:"compress_command"`
+	CompressExt     string   `json:"compress_ext"`
+
+	// Pipeline, if set, processes archives in place of Compress, each
+	// stage writing into the next, e.g. gzip then a cipher, to an archive
+	// named with the stage extensions in order. MergePeriod still uses
+	// gzip, EncryptKey seals the result.
+	Pipeline []ArchiveStage `json:"-"`
+
+	// OpenFunc, if set, opens the output Rotate switches to in place of
+	// the default os.OpenFile of the log path.
+	OpenFunc func(path string) (io.WriteCloser, error) `json:"-"`
+	Mmap     bool                                      `json:"mmap"` // open the new file with OpenMmap, unless OpenFunc is set
+
+	// LazyIdle, when positive, opens the new file with OpenLazy, unless
+	// OpenFunc or Mmap is set. Rotate skips a file not written since.
+	LazyIdle time.Duration `json:"lazy_idle"`
+
+	// LiveGzip compresses the active file as it is written, see OpenGzip.
+	// Archives are named name.<suffix>.gz and need no Compress pass, but
+	// the live file can't be grepped or tailed. StartRotate flushes it
+	// every CheckInterval (default a second). After a crash the file ends
+	// in an unfinished member, readable up to the last flush, and gzip
+	// readers stop there: use RotateOnStart so the next run archives it
+	// rather than appending a member behind it.
+	LiveGzip bool `json:"live_gzip"`
+
+	// GzipFlushEvery, with LiveGzip, flushes the file after that many
+	// records, so tailing readers such as zcat -f can decompress up to the
+	// last one. Each flush ends a deflate block and adds a 5 byte marker:
+	// flushing every record of typical 80 byte lines makes the file larger
+	// than uncompressed, every 10 about 1.5 times the size without
+	// flushes, every 100 a few percent larger.
+	GzipFlushEvery int `json:"gzip_flush_every"`
+
+	// EncryptKey, a 16, 24 or 32 byte AES key, seals each archive (after
+	// compression) into a .enc file readable with DecryptArchive, the
+	// plaintext is wiped. It can't be combined with MergePeriod.
+	EncryptKey []byte `json:"encrypt_key,omitempty"`
+
+	// ArchiveSink, if set, is given each finished archive (compressed and
+	// encrypted as configured) by base name to copy it to, e.g. a pipe to
+	// an upload command. The local archive is removed once shipped unless
+	// KeepShipped is set, and kept if the sink fails. MergePeriod archives
+	// are not shipped.
+	ArchiveSink func(name string) (io.WriteCloser, error) `json:"-"`
+	KeepShipped bool                                      `json:"keep_shipped"`
+
+	// BeforeDelete, if set, is called with each file cleanup is about to
+	// remove, returning false keeps the file this pass.
+	BeforeDelete func(path string) (deleteOK bool) `json:"-"`
+
+	// TrashDir, if set, is where cleanup moves the archives retention
+	// drops, relative to the log directory unless absolute, e.g. ".trash".
+	// They are removed for good TrashRetention (default a day) later.
+	// Removals for free space and content-addressed archives skip it.
+	TrashDir       string        `json:"trash_dir"`
+	TrashRetention time.Duration `json:"trash_retention"`
+
+	// ShouldRotate, if set, is polled by StartRotate every CheckInterval
+	// (default a second) and triggers a rotation when it returns true.
+	ShouldRotate  func() bool   `json:"-"`
+	CheckInterval time.Duration `json:"check_interval"`
+
+	// SyncInterval, when positive, makes StartRotate flush and Sync the
+	// output that often, bounding what a crash loses. Sync errors go to
+	// the ErrorHandler.
+	SyncInterval time.Duration `json:"sync_interval"`
+
+	// UsageWarnBytes makes StartRotate check the archives total size every
+	// CheckInterval and log a Warning above it, at most once per
+	// UsageWarnInterval (default an hour).
+	UsageWarnBytes    int64         `json:"usage_warn_bytes"`
+	UsageWarnInterval time.Duration `json:"usage_warn_interval"`
+
+	// Cron, a five field spec such as "0 2 * * *", schedules rotations
+	// in place of Duration, see parseCron. Archives are named to the
+	// minute and, as rotations are uneven, kept for MaxAge.
+	Cron string `json:"cron"`
+
+	// MaxAge removes archives older than it, default Duration*MaxBackups.
+	MaxAge time.Duration `json:"max_age"`
+
+	// MinKeep is the number of newest archives retention keeps whatever
+	// their age, so quiet periods don't leave no history. MinFreeBytes
+	// may still remove them.
+	MinKeep int `json:"min_keep"`
+
+	// SuffixTimeFunc, if set, gives the time archive suffixes are made
+	// from in place of the clock, e.g. a business date. Record times and
+	// the schedule still follow the clock, retention goes by the suffix.
+	SuffixTimeFunc func() time.Time `json:"-"`
+
+	// Retention, if set, chooses the archives to remove in place of the
+	// MaxAge policy. MinFreeBytes still applies to what it keeps.
+	Retention RetentionPolicy `json:"-"`
+}
+
+// Collision chooses what Rotate does when an archive of the period
+// already exists, e.g. after a second rotation in the period.
+type Collision int
+
+const (
+	// CollisionCounter names the archive name.<suffix>.N, N past the
+	// highest on disk, keeping both.
+	CollisionCounter Collision = iota
+	// CollisionOverwrite replaces the existing archive.
+	CollisionOverwrite
+	// CollisionError fails the rotation, the log file is left in place.
+	CollisionError
+)
+
+// Filter reports whether a record at level with the formatted msg
+// should be written.
+type Filter func(level Level, msg string) bool
+
+func (rc *RotateConfig) maxBackups() int {
+	if rc.MaxBackups > 0 {
+		return rc.MaxBackups
+	}
+	return rc.Rotate
+}
+
+// encryptMerged reports the unsupported EncryptKey with MergePeriod, whose
+// merged .gz archives would stay in plaintext.
+func (rc *RotateConfig) encryptMerged() bool {
+	return len(rc.EncryptKey) > 0 && rc.MergePeriod && rc.Compress && !rc.LiveGzip
+}
+
+// mkdir creates the parent directories of path, MkdirAll accepts a
+// directory created concurrently.
+func (rc *RotateConfig) mkdir(path string) error {
+	mode := rc.DirMode
+	if mode == 0 {
+		mode = 0755
+	}
+	return os.MkdirAll(filepath.Dir(path), mode)
+}
+
+// namedFile is an output backed by a file path, such as *os.File.
+type namedFile interface {
+	Name() string
+}
+
+// LevelWriter is an output taking each record with its level, such as the
+// Windows Event Log of eventlogsink. It gets the prefix and message alone,
+// without time, caller or level tag, ending in a newline, and is not
+// rotated. MaxMessageBytes, Mirror, Fallback and the disk full handling
+// apply as to other outputs.
+type LevelWriter interface {
+	WriteLevel(level Level, msg string) error
 }
 
 type Logger struct {
+	seq uint64 // last IncludeSeq number, first for 64-bit atomic alignment
+
+	dropFiltered uint64 // records dropped by the Filter, see Stats
+	dropDiskFull uint64 // records dropped while the disk was full
+	dropSampled  uint64 // records dropped by Sampling
+	plainBytes   uint64 // size of the archives compressed, before
+	packedBytes  uint64 // and after compression
+	loopGID      uint64 // goroutine of the StartRotate loop, see closeChannel
+
 	*log.Logger
-	Level Level
+	Level  Level // threshold, change it with SetLevel while logging
+	Format Format
+
+	// MaxMessageBytes, when positive, truncates longer text records
+	// including their prefix and tag, appending "...[truncated N bytes]".
+	MaxMessageBytes int
+
+	// LineFormat, if set, lays out text records in place of the stdlib
+	// layout, e.g. "{time} {message} {level}", see formatLine.
+	LineFormat string
+
+	// SchemaVersion, when positive, is written as a leading v=N key of
+	// FormatLogfmt records for consumers to tell layouts apart. Text
+	// records don't carry it.
+	SchemaVersion int
 
-	w io.Writer
+	// Encoder, if set, renders records in place of Format, LineFormat and
+	// TimeZone, see Encoder. MaxMessageBytes still applies.
+	Encoder Encoder
 
-	rotateCfg    *RotateConfig
-	rotateCh     chan bool
-	suffixFormat string
+	// TimeZone, if set, is the zone of record times in place of the local
+	// one or LUTC. Archive names are not affected.
+	TimeZone *time.Location
+
+	// ErrorHandler, if set, is called with the write errors the Logger
+	// handles itself, such as a full disk, outside of any lock.
+	ErrorHandler func(err error)
+
+	// CheckFormat, for development, passes the ErrorHandler an error for
+	// each record whose format verbs didn't match its arguments, seen as
+	// "%!" in the message. The record is still written.
+	CheckFormat bool
+
+	// FallbackAfter, when positive, switches records to Fallback (default
+	// os.Stderr) after that many consecutive write errors other than a full
+	// disk, with a Critical. The output is retried every second and used
+	// again once a write succeeds.
+	FallbackAfter int
+	Fallback      io.Writer
+
+	// Mirror, if set, also gets every record as formatted here, e.g. a
+	// Logger of NewFile on another volume rotated on its own. Either output
+	// failing doesn't stop the other, the errors of Mirror go to this
+	// ErrorHandler unless it handles them itself, as a full disk.
+	Mirror *Logger
+
+	// SinkFactory, if set, is asked once per level for a writer its
+	// records go to in place of the output, e.g. a topic per severity.
+	// While it fails the records go to the output. It must not log through
+	// the Logger. Close closes the writers that are io.Closers.
+	SinkFactory func(level Level) (io.Writer, error)
+
+	// Sampling thins out the records of the levels it has an entry for,
+	// see Sample. Levels without one, by default all, are not sampled. Set
+	// it before logging.
+	Sampling map[Level]Sample
+
+	// StackDepth, when positive, appends the stack of the caller, at most
+	// StackDepth frames, to records at StackLevel and above.
+	StackLevel Level
+	StackDepth int
+
+	// FlushOnLevel, above LevelDebug, flushes an output buffering records,
+	// such as OpenGzip or a bufio.Writer, after each record at that level
+	// or above, writing out the records pending before it too.
+	FlushOnLevel Level
+
+	// IncludeSeq prefixes messages with "seq=N", a per Logger sequence
+	// number that keeps counting across rotations.
+	IncludeSeq bool
+
+	// IncludeGID prefixes messages with "gid=N", the ID of the logging
+	// goroutine. Getting it means formatting the goroutine's stack header,
+	// around a microsecond per record: it is meant for debugging.
+	IncludeGID bool
+
+	root     *Logger // the Logger a WithLevel child writes through
+	name     string  // dotted name of a Named child
+	w        io.Writer
+	fileName string // path of the rotated file, empty if output isn't a namedFile
+	owned    bool   // w was opened by the Logger, see Close
+
+	rotateCfg *RotateConfig
+	rotateCh  chan bool
+	cron      *cronSchedule // parsed RotateConfig.Cron
+
+	rotateMu sync.Mutex // serializes Rotate, Reopen and SetOutput
+
+	mu     sync.Mutex
+	paused bool // timer driven rotation suspended by Pause
+	missed bool // a rotation came due while paused
+
+	lastRotate time.Time // when Rotate last switched files
+	next       time.Time // boundary the StartRotate loop waits for, zero if not running
+
+	lastUsageWarn time.Time
+	fullSince     time.Time  // first write failing for lack of space, zero if none
+	fullRetry     time.Time  // when writes are tried again after fullSince
+	dropped       int        // records dropped since fullSince
+	fullErr       error      // write error entering degraded mode, for report
+	fullNotice    string     // Warning on leaving degraded mode, for report
+	sidecars      []*sidecar // files rotated along, see AddSidecar
+	notify        []chan RotateEvent
+
+	failures       int       // consecutive failed writes, see FallbackAfter
+	fallbackSince  time.Time // when records went to the fallback, zero if not
+	primaryRetry   time.Time // when the output is tried again after fallbackSince
+	fallbackNotice string    // record on switching output, for report
+	fallbackLevel  Level     // level of fallbackNotice
+	mirrorErr      error     // Mirror write error, for report
+
+	samples *[LevelCritical + 1]sampleCounter // per level, allocated for 64-bit alignment
+
+	filter atomic.Value // Filter
+	tags   atomic.Value // map[Level]string set by SetLevelTag
+
+	mergeMu sync.Mutex // serializes appends to period archives
+	casMu   sync.Mutex // serializes ContentAddressed index updates
+	sinkMu  sync.Mutex // guards sinks
+	sinks   map[Level]io.Writer
+	pending sync.WaitGroup // async compress and clean after Rotate
+	loop    sync.WaitGroup // the StartRotate goroutine
+
+	enabledMask  uint32 // levels set by EnableLevels, 0 for threshold mode
+	disabledMask uint32 // levels set by DisableLevel
 }
 
 // @see log.New
 func New(out io.Writer, prefix string, flag int, level Level, rc *RotateConfig) *Logger {
 	l := &Logger{
-		Logger:    log.New(out, prefix, flag),
 		Level:     level,
 		w:         out,
 		rotateCfg: rc,
+		samples:   new([LevelCritical + 1]sampleCounter),
+	}
+	l.Logger = log.New(recordWriter{l}, prefix, flag)
+	if f, ok := out.(namedFile); ok {
+		l.fileName = f.Name()
 	}
 
 	return l
 }
 
-func (l *Logger) SetOutput(w io.Writer) {
-	l.w = w
-	l.Logger.SetOutput(w)
+// NewFile opens path for appending as a rotation opens the next file,
+// by OpenFunc, OpenGzip, OpenMmap or OpenLazy as rc sets, creating its
+// directories when rc.MkdirAll is set, and returns a Logger writing to it.
+// What a previous run left is kept even with TruncateNew. Close closes the
+// file.
+func NewFile(path, prefix string, flag int, level Level, rc *RotateConfig) (*Logger, error) {
+	l := New(ioutil.Discard, prefix, flag, level, rc)
+	w, err := l.openFirst(path)
+	if nil != err {
+		return nil, err
+	}
+	l.w, l.owned, l.fileName = w, true, path
+	return l, nil
 }
 
-func (l *Logger) SetLevel(level Level) {
-	l.Level = level
+// openFirst opens the output of NewFile.
+func (l *Logger) openFirst(path string) (io.WriteCloser, error) {
+	rc := l.rotateCfg
+	if nil != rc && (rc.OpenFunc != nil || rc.LiveGzip || rc.Mmap || rc.LazyIdle > 0) {
+		return l.openOutput(path)
+	}
+	f, err := openAppend(path, rc)
+	if nil != err {
+		return nil, err
+	}
+	return f, nil
 }
 
-func (l *Logger) Rotate() (err error) {
+// Close stops rotation, waits for the pending archives, closes the Notify
+// channels and the sidecars and closes the output if the Logger opened
+// it, by NewFile or a rotation, compressing it then with CompressOnClose.
+func (l *Logger) Close() (err error) {
+	l.Stop()
+	l.WaitPending()
+	l.closeNotify()
+	serr := l.closeSinks()
+	if cerr := l.closeSidecars(); nil == serr {
+		serr = cerr
+	}
+	defer func() {
+		if nil == err {
+			err = serr
+		}
+	}()
+	l.mu.Lock()
+	c, closing := l.w.(io.Closer)
+	closing = closing && l.owned
+	if closing {
+		l.owned = false
+		err = c.Close()
+	}
+	fileName := l.fileName
+	l.mu.Unlock()
 
-	if l.rotateCfg.Duration < time.Minute {
-		l.suffixFormat = formatSec
+	if rc := l.rotateCfg; closing && nil == err && fileName != "" && rc != nil && rc.CompressOnClose && !rc.LiveGzip && len(rc.EncryptKey) == 0 {
+		err = l.compressTo(fileName, fileName+".gz", os.O_APPEND)
+	}
+	return
+}
+
+// SetOutput switches to w, waiting for a rotation in progress. Rotation
+// goes on with the path of w if it is a file, such as *os.File, and stops
+// otherwise. The previous output is not closed.
+func (l *Logger) SetOutput(w io.Writer) {
+	l = l.base()
+	l.rotateMu.Lock()
+	defer l.rotateMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w, l.owned, l.fileName = w, false, ""
+	if f, ok := w.(namedFile); ok {
+		l.fileName = f.Name()
+	}
+}
+
+// SetPath switches to the file at path, waiting for a rotation in
+// progress, and rotates it from then on. The previous output is closed but
+// its file is left as is, call Rotate first to archive it.
+func (l *Logger) SetPath(path string) (err error) {
+	l = l.base()
+	l.rotateMu.Lock()
+	defer l.rotateMu.Unlock()
+
+	var w io.WriteCloser
+	if l.rotateCfg != nil {
+		w, err = l.openOutput(path)
 	} else {
-		l.suffixFormat = formatMin
+		w, err = openAppend(path, nil)
 	}
+	if nil != err {
+		l.Error("set path fail: %s", err.Error())
+		return
+	}
+	l.swapOutput(w)
+	l.mu.Lock()
+	l.fileName = path
+	l.mu.Unlock()
+	return
+}
+
+// recordWriter receives the records formatted by the embedded log.Logger.
+type recordWriter struct {
+	l *Logger
+}
+
+func (rw recordWriter) Write(p []byte) (int, error) {
+	if max := rw.l.MaxMessageBytes; max > 0 && len(p) > max {
+		p = truncateRecord(p, max)
+	}
+	return rw.l.writeRaw(p)
+}
 
+// truncateRecord cuts the line p to at most max bytes including a
+// "...[truncated N bytes]" marker, on a UTF-8 boundary. The marker is left
+// out when max can't hold it.
+func truncateRecord(p []byte, max int) []byte {
 	var (
-		fd       *os.File
-		fileName string
+		body   = bytes.TrimSuffix(p, []byte("\n"))
+		cut    = len(body) - max
+		keep   int
+		marker string
 	)
+	for {
+		marker = fmt.Sprintf("...[truncated %d bytes]\n", cut)
+		if keep = max - len(marker); keep < 0 {
+			// no room for the marker, cut the line alone
+			for keep = max - 1; keep > 0 && !utf8.RuneStart(body[keep]); keep-- {
+			}
+			return append(body[:keep:keep], '\n')
+		}
+		for keep > 0 && !utf8.RuneStart(body[keep]) {
+			keep--
+		}
+		if len(body)-keep == cut {
+			break
+		}
+		cut = len(body) - keep
+	}
+	return append(body[:keep:keep], marker...)
+}
+
+// noLevel marks a write that is not a record of a level.
+const noLevel Level = -1
+
+// writeRaw writes p to the output as is, serialized with rotation.
+// Once the disk is full records are dropped until a retry succeeds, see
+// writeFull. It runs inside log.Logger.Output, so what it has to report is
+// left to report.
+func (l *Logger) writeRaw(p []byte) (n int, err error) {
+	return l.writeRecord(noLevel, p)
+}
+
+// writeRecord is writeRaw passing a LevelWriter output p with level,
+// unless level is noLevel.
+func (l *Logger) writeRecord(level Level, p []byte) (n int, err error) {
+	if l.root != nil {
+		return l.root.writeRecord(level, p)
+	}
+	if l.Mirror != nil {
+		defer l.mirror(p)
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.fallbackSince.IsZero() && now.Before(l.primaryRetry) {
+		return l.fallback().Write(p)
+	}
+	if !l.fullSince.IsZero() && now.Before(l.fullRetry) {
+		l.dropped++
+		atomic.AddUint64(&l.dropDiskFull, 1)
+		return 0, errDiskFull
+	}
+	if lw, ok := l.w.(LevelWriter); ok && level != noLevel {
+		if err = lw.WriteLevel(level, string(p)); nil == err {
+			n = len(p)
+		}
+	} else {
+		n, err = l.w.Write(p)
+	}
+	if nil != err {
+		if isDiskFull(err) {
+			l.writeFull(now, err)
+			return
+		}
+		return l.writeFailed(now, p, err)
+	}
+	l.failures = 0
+	if !l.fallbackSince.IsZero() {
+		l.fallbackNotice = fmt.Sprintf("output recovered after %s on the fallback", now.Sub(l.fallbackSince).Round(time.Millisecond))
+		l.fallbackLevel = LevelWarning
+		l.fallbackSince = time.Time{}
+	}
+	if !l.fullSince.IsZero() {
+		l.fullNotice = fmt.Sprintf("disk was full for %s, %d records dropped", now.Sub(l.fullSince).Round(time.Millisecond), l.dropped)
+		l.fullSince, l.dropped = time.Time{}, 0
+	}
+	return
+}
+
+// Sync commits the output to stable storage if it supports Sync, as
+// *os.File does.
+func (l *Logger) Sync() error {
+	l = l.base()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// flush writes out the data an output such as OpenGzip buffers.
+func (l *Logger) flush() error {
+	l = l.base()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f, ok := l.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (l *Logger) path() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fileName
+}
+
+// Writer returns the current output, which changes on rotation. Writes
+// to it bypass leveling and formatting.
+func (l *Logger) Writer() io.Writer {
+	l = l.base()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w
+}
+
+// SetLevel changes the Level threshold, safely while logging.
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreUintptr(l.levelPtr(), uintptr(level))
+}
+
+// level reads Level atomically, as SetLevel may change it concurrently.
+func (l *Logger) level() Level {
+	return Level(atomic.LoadUintptr(l.levelPtr()))
+}
+
+// levelPtr views Level for the atomic functions, int and uintptr have the
+// same size and alignment.
+func (l *Logger) levelPtr() *uintptr {
+	return (*uintptr)(unsafe.Pointer(&l.Level))
+}
+
+// EnableLevels writes exactly the given levels regardless of Level, so
+// unrelated levels such as Debug and Error can be enabled alone. Calling it
+// without levels restores the Level threshold.
+func (l *Logger) EnableLevels(levels ...Level) {
+	var mask uint32
+	for _, level := range levels {
+		mask |= levelBit(level)
+	}
+	atomic.StoreUint32(&l.enabledMask, mask)
+	for {
+		old := atomic.LoadUint32(&l.disabledMask)
+		if atomic.CompareAndSwapUint32(&l.disabledMask, old, old&^mask) {
+			return
+		}
+	}
+}
+
+// DisableLevel silences level entirely, on top of the Level threshold or
+// the levels given to EnableLevels.
+func (l *Logger) DisableLevel(level Level) {
+	for {
+		old := atomic.LoadUint32(&l.disabledMask)
+		if atomic.CompareAndSwapUint32(&l.disabledMask, old, old|levelBit(level)) {
+			return
+		}
+	}
+}
+
+func levelBit(level Level) uint32 {
+	return 1 << uint(level)
+}
+
+// Enabled reports whether a record at level would be written, so callers
+// can skip building costly messages. A Filter is not consulted as it needs
+// the message.
+func (l *Logger) Enabled(level Level) bool {
+	return l.enabled(level)
+}
+
+// enabled reports whether a record at level passes level filtering.
+func (l *Logger) enabled(level Level) bool {
+	bit := levelBit(level)
+	if atomic.LoadUint32(&l.disabledMask)&bit != 0 {
+		return false
+	}
+	if mask := atomic.LoadUint32(&l.enabledMask); mask != 0 {
+		return mask&bit != 0
+	}
+	return level >= l.level()
+}
+
+// SetLevelTag overrides the tag rendered for level by this Logger, e.g.
+// "ERROR:" in place of "[Error]". The package defaults are not changed.
+func (l *Logger) SetLevelTag(level Level, tag string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	old, _ := l.tags.Load().(map[Level]string)
+	tags := make(map[Level]string, len(old)+1)
+	for k, v := range old {
+		tags[k] = v
+	}
+	tags[level] = tag
+	l.tags.Store(tags)
+}
+
+func (l *Logger) levelTag(level Level) string {
+	if tags, _ := l.tags.Load().(map[Level]string); tags != nil {
+		if tag, ok := tags[level]; ok {
+			return tag
+		}
+	}
+	return level.Tag()
+}
+
+// SetFilter installs f to drop records it returns false for. It runs after
+// level filtering and may be swapped while logging, nil removes the filter.
+func (l *Logger) SetFilter(f Filter) {
+	l.filter.Store(f)
+}
+
+// Rotate archives the log file and switches to a new one, see
+// RotateConfig. It is serialized with SetOutput and Reopen, and fails
+// without a RotateConfig.
+func (l *Logger) Rotate() error {
+	l = l.base()
+	l.rotateMu.Lock()
+	defer l.rotateMu.Unlock()
+	return l.rotate(time.Now())
+}
+
+// rotateTick rotates as StartRotate does at a boundary, taking now for the
+// archive suffix and retention, so tests can drive the schedule with
+// simulated times.
+func (l *Logger) rotateTick(now time.Time) error {
+	l = l.base()
+	l.rotateMu.Lock()
+	defer l.rotateMu.Unlock()
+	return l.rotate(now)
+}
+
+// rotate archives the log file as of now.
+func (l *Logger) rotate(now time.Time) (err error) {
+	if l.rotateCfg == nil {
+		return errInvalidRotateConfig
+	}
+	if l.rotateCfg.External {
+		return l.reopen()
+	}
 
-	switch f := l.w.(type) {
-	case *os.File:
-		fd = f
-		fileName = fd.Name()
-	default:
+	var fileName = l.path()
+	if fileName == "" {
 		return
 	}
+	if l.rotateCfg.SkipEmpty {
+		if fi, serr := os.Stat(fileName); nil == serr && fi.Size() == 0 {
+			return
+		}
+	}
+
+	if l.rotateCfg.LazyIdle > 0 {
+		if _, serr := os.Stat(fileName); os.IsNotExist(serr) {
+			return
+		}
+	}
+
+	if l.rotateCfg.encryptMerged() {
+		return errInvalidRotateConfig
+	}
 
 	var (
-		now           = time.Now()
-		suffix        = now.Truncate(l.rotateCfg.Duration).Format(l.suffixFormat)
+		suffix        = l.rotateCfg.suffix(l.suffixTime(now))
 		targetLogName = fmt.Sprintf("%s.%s", fileName, suffix)
+		live          = l.rotateCfg.LiveGzip
+		merge         = !live && l.rotateCfg.Compress && l.rotateCfg.MergePeriod
 	)
 
-	err = os.Rename(fileName, targetLogName)
+	if !merge {
+		stem := strings.TrimSuffix(fileName, ".gz")
+		switch l.rotateCfg.Collision {
+		case CollisionCounter:
+			suffix = l.rotateCfg.freeSuffix(stem, suffix)
+			targetLogName = fmt.Sprintf("%s.%s", fileName, suffix)
+		case CollisionError:
+			if l.rotateCfg.archived(stem + "." + suffix) {
+				return fmt.Errorf("archive %s.%s already exists", stem, suffix)
+			}
+		}
+	}
+	if live {
+		// already compressed, keep .gz last
+		targetLogName = fmt.Sprintf("%s.%s.gz", strings.TrimSuffix(fileName, ".gz"), suffix)
+	}
+	var renameTo, fragment = targetLogName, ""
+	if merge {
+		// keep fragments of the same period apart until merged
+		fragment = fmt.Sprintf(".%d", now.UnixNano())
+		renameTo += fragment
+	}
+	renameTo = l.rotateCfg.stage(renameTo)
+
+	err = l.renameLog(fileName, renameTo)
+	if nil != err && l.rotateCfg.MkdirAll && os.IsNotExist(err) {
+		// the file or its directory was removed, nothing to archive
+		return l.reopen()
+	}
 	if nil != err {
 		l.Error("rename fail: %s", err.Error())
 		return err
 	}
 
-	var newFd *os.File
-	newFd, err = os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	var newFd io.WriteCloser
+	newFd, err = l.openOutput(fileName)
 	if nil != err {
 		l.Error("open fail: %s", err.Error())
-		os.Rename(targetLogName, fileName) // rename back?
+		renameFile(renameTo, fileName) // keep writing to the old fd
+		if l.holding() {
+			l.reopen()
+		}
+		if isDiskFull(err) {
+			l.handleError(err)
+		}
 		return
 	}
 
-	oldFd := fd
-	l.SetOutput(newFd)
-	oldFd.Close()
+	if l.rotateCfg.CarryTailBytes > 0 && !live {
+		if tail := readTail(renameTo, l.rotateCfg.CarryTailBytes); len(tail) > 0 {
+			newFd.Write(tail)
+		}
+	}
+	l.swapOutput(newFd)
+	var record []byte
+	if l.rotateCfg.RotationRecord {
+		// the old output is closed, its last records are in the archive
+		record = l.rotationRecord(renameTo, targetLogName)
+	}
+	l.mu.Lock()
+	l.lastRotate = now
+	if record != nil && l.w == newFd {
+		newFd.Write(record)
+	}
+	l.mu.Unlock()
+	l.notifyRotate(RotateEvent{Path: fileName, Archive: targetLogName, Time: now})
+	sidecars := l.rotateSidecars(suffix, fragment)
 
-	// compress and clean async
-	go func() {
-		if l.rotateCfg.Compress {
-			l.compress(targetLogName)
+	maintain := func() {
+		l.archive(targetLogName, merge, live)
+		for _, target := range sidecars {
+			l.archive(target, merge, false)
+		}
+		l.compressDelayed(fileName)
+		for _, path := range l.sidecarPaths() {
+			l.compressDelayed(path)
 		}
 		l.cleanOldLogs(now, fileName)
+		for _, path := range l.sidecarPaths() {
+			l.cleanOldLogs(now, path)
+		}
+	}
+	if l.rotateCfg.SyncMaintenance {
+		maintain()
+		return nil
+	}
+
+	// compress and clean async
+	l.pending.Add(1)
+	go func() {
+		defer l.pending.Done()
+		maintain()
 	}()
 	return nil
 }
 
+// rotationRecord formats the Info record chaining the file at path,
+// archived as target, to the next one.
+func (l *Logger) rotationRecord(path, target string) []byte {
+	var (
+		buf  bytes.Buffer
+		size int64
+		h    = sha256.New()
+	)
+	if f, err := os.Open(path); nil == err {
+		size, _ = io.Copy(h, f)
+		f.Close()
+	}
+	msg := fmt.Sprintf("rotated from=%s size=%d sha256=%x", filepath.Base(target), size, h.Sum(nil))
+	l.appendRecord(&buf, log.New(&buf, l.Prefix(), l.Flags()), 2, LevelInfo, msg, nil)
+	return buf.Bytes()
+}
+
+// readTail returns the whole lines within the last n bytes of path, past
+// any zero padding as OpenMmap leaves it.
+func readTail(path string, n int) []byte {
+	f, err := os.Open(path)
+	if nil != err {
+		return nil
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if nil != err {
+		return nil
+	}
+	size := fi.Size()
+	start := size - int64(n)
+	if start < 0 {
+		start = 0
+	}
+	// one byte more tells whether the tail starts on a line
+	from := start
+	if from > 0 {
+		from--
+	}
+	buf := make([]byte, size-from)
+	if _, err = f.ReadAt(buf, from); nil != err {
+		return nil
+	}
+	buf = bytes.TrimRight(buf, "\x00")
+	if start > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return nil
+		}
+		buf = buf[i+1:]
+	}
+	return buf
+}
+
+// freeSuffix returns suffix, or suffix.N past the highest N found on
+// disk when stem already has an archive of that period, so a second
+// rotation in a period, by this process or an earlier one, doesn't
+// replace the first and archives keep their order.
+func (rc *RotateConfig) freeSuffix(stem, suffix string) string {
+	if !rc.archived(stem + "." + suffix) {
+		return suffix
+	}
+	var (
+		dir    = filepath.Dir(stem)
+		prefix = filepath.Base(stem) + "." + suffix + "."
+		names  []string
+		n      = 1
+	)
+	if files, err := ioutil.ReadDir(dir); nil == err {
+		for _, fi := range files {
+			names = append(names, strings.TrimPrefix(fi.Name(), "."))
+		}
+	}
+	if rc.ContentAddressed {
+		for name := range readIndex(dir) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		idx := strings.TrimPrefix(name, prefix)
+		if i := strings.IndexByte(idx, '.'); i >= 0 {
+			idx = idx[:i]
+		}
+		if i, err := strconv.Atoi(idx); nil == err && i >= n {
+			n = i + 1
+		}
+	}
+	for ; rc.archived(fmt.Sprintf("%s.%s.%d", stem, suffix, n)); n++ {
+	}
+	return fmt.Sprintf("%s.%d", suffix, n)
+}
+
+// archived reports whether an archive named target exists in any form.
+func (rc *RotateConfig) archived(target string) bool {
+	if rc.ContentAddressed {
+		if _, ok := readIndex(filepath.Dir(target))[filepath.Base(target)+"."+rc.compressExt()]; ok {
+			return true
+		}
+	}
+	for _, ext := range []string{"", ".gz", "." + rc.compressExt()} {
+		for _, enc := range []string{"", ".enc"} {
+			if _, err := os.Lstat(target + ext + enc); nil == err {
+				return true
+			}
+		}
+	}
+	if stage := rc.stage(target); stage != target {
+		// a CompressDirect archive in the making, or left by a crash
+		for _, ext := range []string{"", "." + rc.compressExt()} {
+			if _, err := os.Lstat(stage + ext); nil == err {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// archive compresses and encrypts a renamed file as configured, unless
+// CompressDelay leaves that to compressDelayed.
+func (l *Logger) archive(target string, merge, live bool) {
+	if merge {
+		if nil == l.compressPeriod(target) {
+			l.chmodArchive(target + ".gz")
+		}
+		return
+	}
+	if l.rotateCfg.compressDirect() && !live {
+		l.archiveDirect(target)
+		return
+	}
+	if l.rotateCfg.Compress && l.rotateCfg.CompressDelay > 0 && !live {
+		l.chmodArchive(target)
+		return
+	}
+	l.finishArchive(target, live)
+}
+
+// chmodArchive applies ArchiveMode to the archive file path.
+func (l *Logger) chmodArchive(path string) {
+	if l.rotateCfg.ArchiveMode == 0 {
+		return
+	}
+	if err := os.Chmod(path, l.rotateCfg.ArchiveMode); nil != err {
+		l.Error("chmod archive err:%s", err.Error())
+	}
+}
+
+// compressDelayed finishes the archives of fileName past the CompressDelay
+// newest ones that are still plain.
+func (l *Logger) compressDelayed(fileName string) {
+	if !l.rotateCfg.Compress || l.rotateCfg.CompressDelay <= 0 || l.rotateCfg.LiveGzip {
+		return
+	}
+	archives, err := l.scanArchives(fileName)
+	if nil != err {
+		return
+	}
+	for i, a := range archives {
+		if i >= l.rotateCfg.CompressDelay && !a.Compressed && !a.Encrypted {
+			l.finishArchive(a.Path, false)
+		}
+	}
+}
+
+// finishArchive compresses, encrypts and ships the archive target.
+func (l *Logger) finishArchive(target string, live bool) {
+	if len(l.rotateCfg.Pipeline) > 0 && !live {
+		plain := fileSize(target)
+		if nil == l.runPipeline(target) {
+			target += "." + l.rotateCfg.pipelineExt()
+			l.countCompressed(plain, fileSize(target))
+		}
+	} else if l.rotateCfg.Compress && !live {
+		sum, plain := l.contentSum(target), fileSize(target)
+		if nil == l.compress(target) {
+			target += "." + l.rotateCfg.compressExt()
+			l.countCompressed(plain, fileSize(target))
+			target = l.addressContent(target, sum)
+		}
+	}
+	l.sealArchive(target)
+}
+
+// archiveDirect compresses the archive target from its CompressDirect
+// stage, renaming the result into place once complete.
+func (l *Logger) archiveDirect(target string) {
+	var (
+		staged     = l.rotateCfg.stage(target)
+		ext        = "." + l.rotateCfg.compressExt()
+		sum, plain = l.contentSum(staged), fileSize(staged)
+	)
+	if err := l.compress(staged); nil != err {
+		// keep it as a plain archive
+		if err = renameFile(staged, target); nil == err {
+			l.sealArchive(target)
+		}
+		return
+	}
+	if err := renameFile(staged+ext, target+ext); nil != err {
+		l.Error("rename compressed archive err:%s", err.Error())
+		return
+	}
+	target += ext
+	l.countCompressed(plain, fileSize(target))
+	l.sealArchive(l.addressContent(target, sum))
+}
+
+// sealArchive encrypts and ships the archive target as configured.
+func (l *Logger) sealArchive(target string) {
+	if len(l.rotateCfg.EncryptKey) > 0 && nil == l.encrypt(target) {
+		target += ".enc"
+	}
+	l.chmodArchive(target)
+	if l.rotateCfg.ArchiveSink != nil {
+		l.ship(target)
+	}
+}
+
+// swapOutput switches to w and closes the previous writer, the records
+// held by renameLog go to w first.
+func (l *Logger) swapOutput(w io.Writer) {
+	l.mu.Lock()
+	old := l.w
+	if h, ok := old.(*heldRecords); ok {
+		w.Write(h.Bytes())
+	}
+	l.w, l.owned = w, true
+	l.mu.Unlock()
+	if c, ok := old.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// openOutput opens the writer a rotation switches to, by OpenFunc if set.
+func (l *Logger) openOutput(name string) (w io.WriteCloser, err error) {
+	w, err = l.open(name)
+	// retry once more if the directory is removed again before the open
+	for i := 0; i < 2 && nil != err && l.rotateCfg.MkdirAll && os.IsNotExist(err); i++ {
+		if err = l.rotateCfg.mkdir(name); nil == err {
+			w, err = l.open(name)
+		}
+	}
+	return
+}
+
+func (l *Logger) open(name string) (io.WriteCloser, error) {
+	if l.rotateCfg.OpenFunc != nil {
+		return l.openFunc(name)
+	}
+	if l.rotateCfg.LiveGzip {
+		return openGzip(name, l.rotateCfg.GzipFlushEvery)
+	}
+	if l.rotateCfg.Mmap {
+		return OpenMmap(name)
+	}
+	if l.rotateCfg.LazyIdle > 0 {
+		return OpenLazy(name, l.rotateCfg.LazyIdle)
+	}
+	return l.openFile(name)
+}
+
+// openFile opens the log file a rotation switches to.
+func (l *Logger) openFile(name string) (*os.File, error) {
+	flag := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+	if l.rotateCfg.TruncateNew {
+		flag = os.O_WRONLY | os.O_TRUNC | os.O_CREATE
+	}
+	f, err := os.OpenFile(name, flag, 0644)
+	if nil == err {
+		err = l.rotateCfg.preallocate(f)
+	}
+	return f, err
+}
+
+// preallocate reserves Preallocate bytes for f if it is empty, closing f
+// when that fails.
+func (rc *RotateConfig) preallocate(f *os.File) error {
+	if rc == nil || rc.Preallocate <= 0 {
+		return nil
+	}
+	fi, err := f.Stat()
+	if nil == err && fi.Size() == 0 {
+		err = preallocate(f, rc.Preallocate)
+	}
+	if nil != err {
+		f.Close()
+	}
+	return err
+}
+
+// log is called directly by every leveled method and Log, so the caller
+// they report is theirs.
 func (l *Logger) log(level Level, format string, v ...interface{}) {
-	if level < l.Level {
+	if !l.enabled(level) {
 		return
 	}
-	l.Output(3, fmt.Sprint(level.String(), fmt.Sprintf(format, v...)))
+	s := fmt.Sprintf(format, v...)
+	if l.CheckFormat && strings.Contains(s, "%!") {
+		defer l.handleError(fmt.Errorf("format %q doesn't match its %d arguments: %s", format, len(v), s))
+	}
+	if l.StackDepth > 0 && level >= l.StackLevel {
+		s += stack(1, l.StackDepth)
+	}
+	l.output(4, level, s)
+}
+
+// output writes an already formatted message at level, calldepth is counted
+// the same way as log.Logger.Output but includes this frame.
+func (l *Logger) output(calldepth int, level Level, s string) error {
+	return l.outputFields(calldepth+1, level, s, nil)
+}
+
+// outputFields is output for a message with fields, which the Filter sees
+// rendered as text.
+func (l *Logger) outputFields(calldepth int, level Level, s string, fields []Field) error {
+	if !l.sample(level) {
+		return nil
+	}
+	if f, _ := l.filter.Load().(Filter); f != nil && !l.filterKeeps(f, level, s+textFields(fields)) {
+		atomic.AddUint64(&l.base().dropFiltered, 1)
+		return nil
+	}
+	return l.emit(calldepth+1, level, s, fields)
+}
+
+// emit writes s past the Filter, fields are keys of their own in logfmt
+// and appended to s otherwise.
+func (l *Logger) emit(calldepth int, level Level, s string, fields []Field) error {
+	defer l.report(true)
+	if l.FlushOnLevel > LevelDebug && level >= l.FlushOnLevel {
+		defer l.flush()
+	}
+	if l.SinkFactory != nil {
+		if w := l.levelSink(level); w != nil {
+			return l.writeSink(w, calldepth, level, s, fields)
+		}
+	}
+	s = l.decorate(s)
+	if _, ok := l.Writer().(LevelWriter); ok {
+		_, err := l.writeRecord(level, l.levelRecord(s, fields))
+		return err
+	}
+	if l.Encoder != nil {
+		return l.encode(level, s, fields)
+	}
+	if l.Format == FormatBinary {
+		return l.writeBinary(level, s+textFields(fields))
+	}
+	if l.Format == FormatLogfmt {
+		_, err := recordWriter{l}.Write(l.formatLogfmt(calldepth, level, s, fields))
+		return err
+	}
+	s += textFields(fields)
+	if l.LineFormat != "" {
+		_, err := recordWriter{l}.Write([]byte(l.formatLine(calldepth, level, s)))
+		return err
+	}
+	if l.TimeZone != nil {
+		_, err := recordWriter{l}.Write([]byte(l.formatText(calldepth, level, s)))
+		return err
+	}
+	return l.Logger.Output(calldepth, l.levelTag(level)+tagSep+s)
+}
+
+// levelRecord formats s for a LevelWriter output: the prefix, message
+// and fields on a line, cut to MaxMessageBytes.
+func (l *Logger) levelRecord(s string, fields []Field) []byte {
+	p := []byte(l.Prefix() + s + textFields(fields))
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		p = append(p, '\n')
+	}
+	if max := l.MaxMessageBytes; max > 0 && len(p) > max {
+		p = truncateRecord(p, max)
+	}
+	return p
+}
+
+// decorate adds the IncludeSeq and IncludeGID prefixes and the Named tag
+// to s.
+func (l *Logger) decorate(s string) string {
+	if l.IncludeGID {
+		s = fmt.Sprintf("gid=%d %s", goroutineID(), s)
+	}
+	if l.IncludeSeq {
+		s = fmt.Sprintf("seq=%d %s", atomic.AddUint64(&l.base().seq, 1), s)
+	}
+	if l.name != "" {
+		s = "[" + l.name + "] " + s
+	}
+	return s
 }
 
 func (l *Logger) Log(level Level, format string, v ...interface{}) {
@@ -206,51 +1392,306 @@ func (l *Logger) Critical(format string, v ...interface{}) {
 	l.log(LevelCritical, format, v...)
 }
 
-func (l *Logger) StartRotate() (err error) {
-	if l.rotateCfg == nil || l.rotateCfg.Rotate <= 0 || l.rotateCfg.Duration < 1*time.Second {
-		return errInvalidRotateConfig
+// StartRotate starts timer driven rotation, replacing a loop already
+// running. The returned stop ends this loop alone and may be called more
+// than once, Stop ends whichever is running.
+func (l *Logger) StartRotate() (stop func(), err error) {
+	if l.rotateCfg != nil && l.rotateCfg.External {
+		return l.startWatch()
+	}
+	if l.rotateCfg != nil && l.rotateCfg.Cron != "" {
+		if err = l.parseCron(); nil != err {
+			return
+		}
+	} else if l.rotateCfg == nil || l.rotateCfg.maxBackups() <= 0 || l.rotateCfg.Duration < 1*time.Second {
+		return nil, errInvalidRotateConfig
+	}
+	if l.rotateCfg.encryptMerged() {
+		return nil, errInvalidRotateConfig
 	}
 
-	l.closeChannel()
-	l.rotateCh = make(chan bool)
+	if l.rotateCfg.RotateOnStart {
+		if fi, serr := os.Stat(l.path()); nil == serr && fi.Size() > 0 {
+			if err = l.Rotate(); nil != err {
+				return
+			}
+		}
+	}
+
+	ch, stop := l.newLoop()
+
+	// a rotation since the last boundary, e.g. an explicit Rotate,
+	// makes the timer skip the next one
+	since := time.Now()
+	l.runLoop(func() {
+		defer func() {
+			l.mu.Lock()
+			l.next = time.Time{}
+			l.mu.Unlock()
+		}()
+		var check <-chan time.Time
+		if l.rotateCfg.ShouldRotate != nil || l.rotateCfg.UsageWarnBytes > 0 || l.rotateCfg.LiveGzip {
+			interval := l.rotateCfg.CheckInterval
+			if interval <= 0 {
+				interval = time.Second
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			check = ticker.C
+		}
+		var syncTick <-chan time.Time
+		if l.rotateCfg.SyncInterval > 0 {
+			ticker := time.NewTicker(l.rotateCfg.SyncInterval)
+			defer ticker.Stop()
+			syncTick = ticker.C
+		}
 
-	go func() {
 		for {
 
-			next := (time.Now().Add(l.rotateCfg.Duration)).Truncate(l.rotateCfg.Duration)
+			next := l.nextRotation(time.Now())
+			l.mu.Lock()
+			l.next = next
+			l.mu.Unlock()
 			wait := next.Sub(time.Now())
 			select {
-			case <-l.rotateCh:
+			case <-ch:
+				return
 			case <-time.After( /*l.rotateCfg.Duration*/ wait):
+				if l.rotatedSince(since) {
+					since = time.Now()
+					continue
+				}
+			case now := <-check:
+				if l.rotateCfg.UsageWarnBytes > 0 {
+					l.checkDiskUsage(now)
+				}
+				if l.rotateCfg.LiveGzip {
+					l.flush()
+				}
+				if l.rotateCfg.ShouldRotate == nil || !l.shouldRotate() {
+					continue
+				}
+			case <-syncTick:
+				l.flush()
+				if err := l.Sync(); nil != err {
+					l.handleError(err)
+				}
+				continue
 			}
-			l.Rotate()
+			if l.skipPaused() {
+				continue
+			}
+			l.rotateTick(time.Now())
+			since = time.Now()
 		}
-	}()
+	})
 	return
 }
 
+// NextRotation returns when StartRotate rotates next, false if timer
+// driven rotation isn't running.
+func (l *Logger) NextRotation() (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next, !l.next.IsZero()
+}
+
+// rotatedSince reports whether Rotate switched files after t.
+func (l *Logger) rotatedSince(t time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastRotate.After(t)
+}
+
+// skipPaused reports whether a due rotation must be skipped because
+// rotation is paused, remembering it for Resume.
+func (l *Logger) skipPaused() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.paused {
+		l.missed = true
+	}
+	return l.paused
+}
+
+// Pause suspends timer driven rotation until Resume is called.
+// Explicit calls to Rotate are not affected.
+func (l *Logger) Pause() {
+	l.mu.Lock()
+	l.paused = true
+	l.mu.Unlock()
+}
+
+// Resume re-arms rotation suspended by Pause. If a rotation came due while
+// paused it is done at once when RotateOnResume is set, otherwise it waits
+// for the next boundary.
+func (l *Logger) Resume() error {
+	l.mu.Lock()
+	missed := l.paused && l.missed
+	l.paused, l.missed = false, false
+	l.mu.Unlock()
+
+	if missed && l.rotateCfg != nil && l.rotateCfg.RotateOnResume {
+		return l.Rotate()
+	}
+	return nil
+}
+
+// WaitPending blocks until the compression and cleanup started by previous
+// rotations are done.
+func (l *Logger) WaitPending() {
+	l.pending.Wait()
+}
+
+// Stop ends timer driven rotation, returning once a rotation in progress
+// is done, so WaitPending after it covers every rotation.
+// Called from the loop itself, e.g. by ShouldRotate or the ErrorHandler,
+// it returns at once and the loop exits after the call.
 func (l *Logger) Stop() {
 	l.closeChannel()
 }
 
+// closeChannel stops the StartRotate goroutine and waits for it to exit.
 func (l *Logger) closeChannel() {
+	l.mu.Lock()
 	if l.rotateCh != nil {
 		close(l.rotateCh)
 		l.rotateCh = nil
 	}
+	l.mu.Unlock()
+	l.waitLoop()
 }
 
-func (l *Logger) genSuffixStr() string {
+// waitLoop waits for the StartRotate goroutine to exit, unless called
+// from it, e.g. by ShouldRotate or the ErrorHandler, as it would wait for
+// itself. The loop exits once that call returns.
+func (l *Logger) waitLoop() {
+	if id := atomic.LoadUint64(&l.loopGID); id != 0 && id == goroutineID() {
+		return
+	}
+	l.loop.Wait()
+}
+
+// runLoop runs f as the StartRotate goroutine.
+func (l *Logger) runLoop(f func()) {
+	l.loop.Add(1)
+	go func() {
+		defer l.loop.Done()
+		id := goroutineID()
+		atomic.StoreUint64(&l.loopGID, id)
+		defer atomic.CompareAndSwapUint64(&l.loopGID, id, 0)
+		f()
+	}()
+}
+
+// newLoop stops the running loop and returns the channel closed to stop
+// the next one, with the stop function of StartRotate for it.
+func (l *Logger) newLoop() (ch chan bool, stop func()) {
+	l.closeChannel()
+	ch = make(chan bool)
+	l.mu.Lock()
+	l.rotateCh = ch
+	l.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			running := l.rotateCh == ch
+			if running {
+				close(ch)
+				l.rotateCh = nil
+			}
+			l.mu.Unlock()
+			if running {
+				l.waitLoop()
+			}
+		})
+	}
+}
+
+// compressDirect reports whether archives are compressed from a hidden
+// stage, see CompressDirect.
+func (rc *RotateConfig) compressDirect() bool {
+	return rc.CompressDirect && rc.Compress && !rc.MergePeriod && !rc.LiveGzip &&
+		len(rc.Pipeline) == 0 && rc.CompressDelay <= 0
+}
+
+// stage returns where rotation moves the file archived as target.
+func (rc *RotateConfig) stage(target string) string {
+	if !rc.compressDirect() {
+		return target
+	}
+	return filepath.Join(filepath.Dir(target), "."+filepath.Base(target))
+}
 
-	var t = time.Now().Truncate(l.rotateCfg.Duration)
-	return t.Format(l.suffixFormat)
+// suffixFormat is the time layout of archive suffixes, to the second for
+// periods under a minute and to the minute otherwise.
+func (rc *RotateConfig) suffixFormat() string {
+	if rc.NanoSuffix {
+		return formatNano
+	}
+	if rc.Duration < time.Minute && rc.Cron == "" {
+		return formatSec
+	}
+	return formatMin
+}
+
+// suffix is the archive suffix for a rotation at t.
+func (rc *RotateConfig) suffix(t time.Time) string {
+	if rc.NanoSuffix {
+		return t.Format(formatNano)
+	}
+	return rc.periodStart(t).Format(rc.suffixFormat())
+}
+
+func (l *Logger) genSuffixStr() string {
+	return l.rotateCfg.suffix(l.suffixTime(time.Now()))
 }
 
 func (l *Logger) compress(path string) (err error) {
+	if len(l.rotateCfg.CompressCommand) > 0 {
+		return l.compressCommand(path)
+	}
+	return l.compressTo(path, fmt.Sprintf("%s.gz", path), os.O_TRUNC)
+}
+
+// compressPeriod appends the pending fragments of the period archive
+// target, oldest first, to target.gz.
+func (l *Logger) compressPeriod(target string) (err error) {
+	l.mergeMu.Lock()
+	defer l.mergeMu.Unlock()
+
+	gfn := fmt.Sprintf("%s.gz", target)
+	fragments, err := filepath.Glob(target + ".*")
+	if nil != err {
+		l.Error("fail in Glob fragments:%s, err:%s", target, err.Error())
+		return
+	}
+	sort.Strings(fragments)
+
+	for _, fn := range fragments {
+		if fn == gfn {
+			continue
+		}
+		plain, before := fileSize(fn), fileSize(gfn)
+		if err = l.compressTo(fn, gfn, os.O_APPEND); nil != err {
+			return
+		}
+		l.countCompressed(plain, fileSize(gfn)-before)
+	}
+	return
+}
+
+// compressTo gzips path into gfn opened with flag and removes path on
+// success.
+func (l *Logger) compressTo(path, gfn string, flag int) (err error) {
 	var (
 		rawfile *os.File
+		rawinfo os.FileInfo
 		wf      *os.File
 		gzfile  *gzip.Writer
+		start   int64 // size of gfn before this member
 	)
 
 	defer func() {
@@ -258,14 +1699,33 @@ func (l *Logger) compress(path string) (err error) {
 			rawfile.Close()
 		}
 		if nil != gzfile {
-			gzfile.Flush()
-			gzfile.Close()
+			if cerr := gzfile.Close(); nil == err {
+				err = cerr
+			}
 		}
 		if nil != wf {
-			wf.Close()
+			if cerr := wf.Close(); nil == err {
+				err = cerr
+			}
+			if nil != err {
+				// e.g. out of space, drop the partial member and keep path
+				if start == 0 {
+					os.Remove(gfn)
+				} else {
+					os.Truncate(gfn, start)
+				}
+				if isDiskFull(err) {
+					l.handleError(err)
+				}
+			}
 		}
 		if err == nil {
-			os.Remove(path)
+			os.Chtimes(gfn, rawinfo.ModTime(), rawinfo.ModTime())
+			if len(l.rotateCfg.EncryptKey) > 0 {
+				wipeFile(path) // plaintext, only the .gz gets encrypted
+			} else {
+				os.Remove(path)
+			}
 		}
 	}()
 
@@ -274,15 +1734,23 @@ func (l *Logger) compress(path string) (err error) {
 		l.Error("open file for compress err:%s", err.Error())
 		return
 	}
+	if rawinfo, err = rawfile.Stat(); nil != err {
+		l.Error("stat file for compress err:%s", err.Error())
+		return
+	}
 
-	gfn := fmt.Sprintf("%s.gz", path)
-	wf, err = os.OpenFile(gfn, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	wf, err = os.OpenFile(gfn, os.O_WRONLY|flag|os.O_CREATE, 0644)
 	if nil != err {
 		l.Error("open gz file err:%s", err.Error())
 		return
 	}
+	if fi, serr := wf.Stat(); nil == serr {
+		start = fi.Size()
+	}
 
 	gzfile = gzip.NewWriter(wf)
+	gzfile.Name = filepath.Base(path)
+	gzfile.ModTime = rawinfo.ModTime()
 	_, err = io.Copy(gzfile, rawfile)
 	if nil != err {
 		l.Error("write gz file:%s, err:%s", gfn, err.Error())
@@ -291,44 +1759,97 @@ func (l *Logger) compress(path string) (err error) {
 	return
 }
 
-func (l *Logger) isOverdue(now time.Time, ts string) (due bool) {
-	wt, err := time.ParseInLocation(l.suffixFormat, ts, time.Local)
-	if nil != err {
-		l.Error("parse time err. time-str:%s, err:%s", ts, err.Error())
-		return
-	}
+func (l *Logger) isOverdue(now time.Time, wt time.Time) (due bool) {
+	return now.Sub(wt) > time.Duration(l.agePolicy())
+}
 
-	if now.Sub(wt) > l.rotateCfg.Duration*time.Duration(l.rotateCfg.Rotate) {
-		return true
+func (l *Logger) agePolicy() AgePolicy {
+	if l.rotateCfg.MaxAge > 0 {
+		return AgePolicy(l.rotateCfg.MaxAge)
 	}
-	return false
+	return AgePolicy(l.rotateCfg.Duration * time.Duration(l.rotateCfg.maxBackups()))
 }
 
-func (l *Logger) cleanOldLogs(now time.Time, fileName string) (err error) {
-
-	dir := filepath.Dir(fileName)
-	files, err := filepath.Glob(fmt.Sprintf("%s/*", dir))
+func (l *Logger) cleanOldLogs(now time.Time, fileName string) (removed int, freedBytes int64, err error) {
+	archives, err := l.scanArchives(fileName)
 	if nil != err {
-		l.Error("fail in Glob dir:%s, err:%s", dir, err.Error())
 		return
 	}
 
 	var (
-		rx      *regexp.Regexp
-		pattern = fmt.Sprintf("([0-9]{%d})", len(l.suffixFormat))
+		kept []string
+		drop = make(map[string]bool)
 	)
+	for _, fn := range l.selectRetention(archives, now) {
+		drop[fn] = true
+	}
+	for i := 0; i < l.rotateCfg.MinKeep && i < len(archives); i++ {
+		delete(drop, archives[i].Path) // newest first
+	}
+	trash := l.rotateCfg.TrashDir != ""
+	for _, a := range archives {
+		if drop[a.Path] {
+			var (
+				size int64
+				ok   bool
+			)
+			if trash && a.Content == "" {
+				size, ok = l.trashFile(a.Path, now)
+			} else {
+				size, ok = l.removeFile(a.Path)
+			}
+			if ok {
+				removed++
+				freedBytes += size
+			}
+			continue
+		}
+		kept = append(kept, a.Path)
+	}
+	if trash {
+		l.sweepTrash(filepath.Dir(fileName), now)
+	}
 
-	rx, err = regexp.Compile(pattern)
-	if nil != err {
-		l.Error("Failed to compile pattern. pattern:%s, err:%s", pattern, err.Error())
-		return
+	if l.rotateCfg.MinFreeBytes > 0 {
+		// scanArchives sorts newest first
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+		n, size := l.ensureFreeSpace(filepath.Dir(fileName), kept)
+		removed += n
+		freedBytes += size
 	}
+	return
+}
 
-	for _, fn := range files {
-		var match = rx.FindString(fn)
-		if len(match) > 0 && l.isOverdue(now, match) {
-			os.Remove(fn)
+// ensureFreeSpace removes files oldest first until the volume of dir has
+// MinFreeBytes available. Nothing is removed if free space is unknown.
+func (l *Logger) ensureFreeSpace(dir string, oldestFirst []string) (removed int, freedBytes int64) {
+	for _, fn := range oldestFirst {
+		free, ok := diskFree(dir)
+		if !ok || free >= uint64(l.rotateCfg.MinFreeBytes) {
+			return
+		}
+		if size, ok := l.removeFile(fn); ok {
+			removed++
+			freedBytes += size
 		}
 	}
 	return
 }
+
+// removeFile removes fn unless BeforeDelete vetoes it and returns its size.
+func (l *Logger) removeFile(fn string) (size int64, ok bool) {
+	if l.rotateCfg.BeforeDelete != nil && !l.beforeDelete(fn) {
+		return 0, false
+	}
+	if l.rotateCfg.ContentAddressed {
+		if size, ok = l.unindex(fn); ok {
+			return
+		}
+	}
+	if fi, err := os.Stat(fn); nil == err {
+		size = fi.Size()
+	}
+	return size, nil == os.Remove(fn)
+}
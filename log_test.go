@@ -1,11 +1,25 @@
 package rotatelog
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestRotateLoggger(t *testing.T) {
@@ -31,9 +45,64 @@ func BenchmarkRotateLogPrintf(b *testing.B) {
 	}
 }
 
+func TestRotateNilConfig(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	logger := New(f, "", 0, LevelInfo, nil)
+	if err = logger.Rotate(); err != errInvalidRotateConfig {
+		t.Errorf("Rotate() = %v, want errInvalidRotateConfig", err)
+	}
+}
+
+func TestNanoSuffix(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	start := time.Now().Truncate(time.Second) // recent, or retention drops them
+	tick := start
+	logger, err := NewFile(logFile, "", 0, LevelInfo, &RotateConfig{
+		Duration:       time.Hour,
+		MaxBackups:     1000,
+		NanoSuffix:     true,
+		Collision:      CollisionOverwrite,
+		SuffixTimeFunc: func() time.Time { return tick },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		tick = start.Add(time.Duration(i) * 1000) // all within a millisecond
+		logger.Info("record %d", i)
+		if err := logger.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archives, err := logger.Archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != n {
+		t.Fatalf("%d archives, want %d", len(archives), n)
+	}
+	for j, a := range archives {
+		i := n - 1 - j // newest first
+		want := fmt.Sprintf("%s.%s", logFile, start.Add(time.Duration(i)*1000).Format(formatNano))
+		if a.Path != want {
+			t.Errorf("archive %d is %s, want %s", i, a.Path, want)
+		}
+		if b, _ := ioutil.ReadFile(a.Path); string(b) != fmt.Sprintf("[Info] record %d\n", i) {
+			t.Errorf("archive %d has %q", i, b)
+		}
+	}
+}
+
 func TestRotate(t *testing.T) {
-	os.Mkdir("logs", 0755)
-	logFile := "logs/rotatelog.log"
+	logFile := filepath.Join(t.TempDir(), "rotatelog.log")
 
 	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
@@ -55,4 +124,1271 @@ func TestRotate(t *testing.T) {
 	}
 	t.Log("stopping")
 	logger.Stop()
+	logger.WaitPending()
+}
+
+func TestPauseResume(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "pause.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open log file for test fail:%s", err.Error())
+	}
+
+	rotateConfig := &RotateConfig{Duration: time.Second, Rotate: 5, RotateOnResume: true}
+	logger := New(f, "", log.LstdFlags, LevelDebug, rotateConfig)
+	logger.Pause()
+	if _, err = logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Stop()
+	logger.Info("before pause")
+
+	time.Sleep(1500 * time.Millisecond)
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 0 {
+		t.Fatalf("rotated while paused: %v", archives)
+	}
+
+	if err = logger.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 1 {
+		t.Fatalf("want 1 archive after resume, got %v", archives)
+	}
+}
+
+func TestOpenFileTruncateNew(t *testing.T) {
+	for _, tc := range []struct {
+		truncate bool
+		want     string
+	}{
+		{false, "stale\nfresh\n"},
+		{true, "fresh\n"},
+	} {
+		logFile := filepath.Join(t.TempDir(), "open.log")
+		if err := ioutil.WriteFile(logFile, []byte("stale\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{TruncateNew: tc.truncate})
+		f, err := logger.openFile(logFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("fresh\n")
+		f.Close()
+
+		got, _ := ioutil.ReadFile(logFile)
+		if string(got) != tc.want {
+			t.Errorf("TruncateNew=%v: got %q, want %q", tc.truncate, got, tc.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.SetFilter(func(level Level, msg string) bool {
+		return !strings.Contains(msg, "healthz")
+	})
+
+	logger.Info("GET /healthz 200")
+	logger.Error("GET /healthz 500")
+	logger.Info("GET /users 200")
+
+	if out := buf.String(); strings.Contains(out, "healthz") || !strings.Contains(out, "/users") {
+		t.Errorf("unexpected output: %q", out)
+	}
+
+	logger.SetFilter(nil)
+	logger.Info("GET /healthz 200")
+	if !strings.Contains(buf.String(), "healthz") {
+		t.Errorf("filter not removed: %q", buf.String())
+	}
+}
+
+func TestCleanMinFreeBytes(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	now := time.Now()
+
+	var archives []string
+	for i := 3; i > 0; i-- {
+		fn := logFile + "." + now.Add(-time.Duration(i)*time.Minute).Format(formatMin)
+		ioutil.WriteFile(fn, []byte("0123456789"), 0644)
+		archives = append(archives, fn)
+	}
+	ioutil.WriteFile(logFile, []byte("live"), 0644)
+
+	defer func(f func(string) (uint64, bool)) { diskFree = f }(diskFree)
+	diskFree = func(string) (uint64, bool) {
+		free := uint64(5)
+		for _, fn := range archives {
+			if _, err := os.Stat(fn); os.IsNotExist(err) {
+				free += 10
+			}
+		}
+		return free, true
+	}
+
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Minute, Rotate: 100, MinFreeBytes: 25})
+	logger.cleanOldLogs(now, logFile)
+
+	for i, fn := range archives {
+		_, err := os.Stat(fn)
+		if removed := os.IsNotExist(err); removed != (i < 2) {
+			t.Errorf("%s: removed=%v", filepath.Base(fn), removed)
+		}
+	}
+	if _, err := os.Stat(logFile); err != nil {
+		t.Errorf("live file removed: %v", err)
+	}
+}
+
+func TestCleanOldLogsCounts(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	now := time.Now()
+
+	// two overdue archives of 3 and 5 bytes, one still within retention
+	ioutil.WriteFile(logFile+"."+now.Add(-3*time.Hour).Format(formatMin), []byte("abc"), 0644)
+	ioutil.WriteFile(logFile+"."+now.Add(-2*time.Hour).Format(formatMin), []byte("abcde"), 0644)
+	ioutil.WriteFile(logFile+"."+now.Add(-time.Minute).Format(formatMin), []byte("kept"), 0644)
+	ioutil.WriteFile(logFile, []byte("live"), 0644)
+
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, Rotate: 1})
+	removed, freed, err := logger.cleanOldLogs(now, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || freed != 8 {
+		t.Errorf("got removed=%d freed=%d, want 2 and 8", removed, freed)
+	}
+	if left, _ := filepath.Glob(logFile + "*"); len(left) != 2 {
+		t.Errorf("unexpected files left: %v", left)
+	}
+}
+
+func TestEnableLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelInfo, nil)
+	logger.EnableLevels(LevelDebug, LevelError)
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Notice("notice")
+	logger.Warning("warning")
+	logger.Error("error")
+	logger.Critical("critical")
+	if want := "[Debug] debug\n[Error] error\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	logger.EnableLevels()
+	logger.DisableLevel(LevelNotice)
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Notice("notice")
+	logger.Warning("warning")
+	if want := "[Info] info\n[Warning] warning\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	logger := New(ioutil.Discard, "", 0, LevelInfo, nil)
+	if logger.Enabled(LevelDebug) || !logger.Enabled(LevelInfo) {
+		t.Error("Enabled should follow the Info threshold")
+	}
+	logger.SetLevel(LevelDebug)
+	if !logger.Enabled(LevelDebug) {
+		t.Error("Debug should be enabled after SetLevel")
+	}
+
+	logger.EnableLevels(LevelError)
+	if logger.Enabled(LevelDebug) || logger.Enabled(LevelCritical) || !logger.Enabled(LevelError) {
+		t.Error("Enabled should follow EnableLevels")
+	}
+	logger.EnableLevels()
+	logger.DisableLevel(LevelWarning)
+	if logger.Enabled(LevelWarning) || !logger.Enabled(LevelNotice) {
+		t.Error("Enabled should follow DisableLevel")
+	}
+}
+
+func TestMaxBackups(t *testing.T) {
+	now := time.Now()
+	for _, rc := range []*RotateConfig{
+		{Duration: time.Hour, Rotate: 2},
+		{Duration: time.Hour, MaxBackups: 2},
+		{Duration: time.Hour, MaxBackups: 2, Rotate: 10},
+	} {
+		dir := t.TempDir()
+		logFile := filepath.Join(dir, "app.log")
+		for _, age := range []time.Duration{30, 90, 150, 210} {
+			ioutil.WriteFile(logFile+"."+now.Add(-age*time.Minute).Format(formatMin), nil, 0644)
+		}
+
+		logger := New(ioutil.Discard, "", 0, LevelDebug, rc)
+		if removed, _, _ := logger.cleanOldLogs(now, logFile); removed != 2 {
+			t.Errorf("MaxBackups=%d Rotate=%d: removed %d, want 2", rc.MaxBackups, rc.Rotate, removed)
+		}
+	}
+}
+
+func TestMergePeriod(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := &RotateConfig{Duration: 24 * time.Hour, MaxBackups: 5, Compress: true, MergePeriod: true}
+	logger := New(f, "", 0, LevelDebug, rc)
+	for _, msg := range []string{"one", "two", "three"} {
+		logger.Info("%s", msg)
+		if err = logger.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger.WaitPending()
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 || !strings.HasSuffix(archives[0], ".gz") {
+		t.Fatalf("want a single period archive, got %v", archives)
+	}
+
+	gf, err := os.Open(archives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gf.Close()
+	zr, err := gzip.NewReader(gf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[Info] one\n[Info] two\n[Info] three\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+type wrappedFile struct {
+	*os.File
+	writes int
+}
+
+func (w *wrappedFile) Write(p []byte) (int, error) {
+	w.writes++
+	return w.File.Write(p)
+}
+
+func TestOpenFunc(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opened []*wrappedFile
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 5, MergePeriod: true}
+	rc.OpenFunc = func(path string) (io.WriteCloser, error) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		w := &wrappedFile{File: f}
+		opened = append(opened, w)
+		return w, nil
+	}
+	logger := New(f, "", 0, LevelDebug, rc)
+
+	for i := 0; i < 2; i++ {
+		if err = logger.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("after rotation %d", i)
+	}
+
+	if len(opened) != 2 || logger.Writer() != opened[1] {
+		t.Fatalf("OpenFunc writer not in use: opened=%v writer=%v", opened, logger.Writer())
+	}
+	if opened[0].writes != 1 || opened[1].writes != 1 {
+		t.Errorf("unexpected writes %d, %d", opened[0].writes, opened[1].writes)
+	}
+	if data, _ := ioutil.ReadFile(logFile); string(data) != "[Info] after rotation 1\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+func TestBeforeDelete(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	now := time.Now()
+	vetoed := logFile + "." + now.Add(-3*time.Hour).Format(formatMin)
+	allowed := logFile + "." + now.Add(-2*time.Hour).Format(formatMin)
+	ioutil.WriteFile(vetoed, nil, 0644)
+	ioutil.WriteFile(allowed, nil, 0644)
+
+	var asked []string
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 1}
+	rc.BeforeDelete = func(path string) bool {
+		asked = append(asked, path)
+		return path != vetoed
+	}
+	logger := New(ioutil.Discard, "", 0, LevelDebug, rc)
+
+	if removed, _, _ := logger.cleanOldLogs(now, logFile); removed != 1 {
+		t.Errorf("removed %d, want 1", removed)
+	}
+	if len(asked) != 2 {
+		t.Errorf("BeforeDelete called for %v", asked)
+	}
+	if _, err := os.Stat(vetoed); err != nil {
+		t.Errorf("vetoed file removed: %v", err)
+	}
+	if _, err := os.Stat(allowed); !os.IsNotExist(err) {
+		t.Errorf("allowed file kept: %v", err)
+	}
+}
+
+func TestShouldRotate(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deployed int32
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 5, CheckInterval: 10 * time.Millisecond}
+	rc.ShouldRotate = func() bool {
+		return atomic.CompareAndSwapInt32(&deployed, 1, 0)
+	}
+	logger := New(f, "", 0, LevelDebug, rc)
+	if _, err = logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 0 {
+		t.Fatalf("rotated before the condition held: %v", archives)
+	}
+
+	atomic.StoreInt32(&deployed, 1)
+	var archives []string
+	for deadline := time.Now().Add(time.Second); len(archives) == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		archives, _ = filepath.Glob(logFile + ".*")
+	}
+	if len(archives) != 1 {
+		t.Errorf("want one rotation, got %v", archives)
+	}
+
+	// a second trigger in the same period must not replace the first archive
+	atomic.StoreInt32(&deployed, 1)
+	for deadline := time.Now().Add(time.Second); len(archives) == 1 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		archives, _ = filepath.Glob(logFile + ".*")
+	}
+	if len(archives) != 2 || archives[1] != archives[0]+".1" {
+		t.Errorf("want two distinct archives of one period, got %v", archives)
+	}
+}
+
+func TestLevelTag(t *testing.T) {
+	for _, level := range []Level{LevelDebug, LevelInfo, LevelNotice, LevelWarning, LevelError, LevelCritical, Level(42)} {
+		tag := level.Tag()
+		if tag != strings.TrimSpace(tag) || !strings.HasPrefix(tag, "[") || !strings.HasSuffix(tag, "]") {
+			t.Errorf("level %d: unexpected tag %q", level, tag)
+		}
+		if level.String() != tag+" " {
+			t.Errorf("level %d: String() = %q", level, level.String())
+		}
+	}
+}
+
+func TestCallerLine(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", log.Lshortfile, LevelDebug, nil)
+	check := func(name string, line int) {
+		t.Helper()
+		if want := fmt.Sprintf("log_test.go:%d: [", line); !strings.HasPrefix(buf.String(), want) {
+			t.Errorf("%s reported %q, want %q", name, buf.String(), want)
+		}
+		buf.Reset()
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info("x")
+	check("Info", line+1)
+	_, _, line, _ = runtime.Caller(0)
+	logger.Log(LevelWarning, "x")
+	check("Log", line+1)
+	_, _, line, _ = runtime.Caller(0)
+	logger.Printf("x")
+	check("Printf", line+1)
+	_, _, line, _ = runtime.Caller(0)
+	logger.WithLevel(LevelDebug).Log(LevelError, "x")
+	check("child Log", line+1)
+}
+
+func TestCheckFormat(t *testing.T) {
+	var (
+		buf      bytes.Buffer
+		reported []error
+	)
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.ErrorHandler = func(err error) { reported = append(reported, err) }
+	mismatched := "%d requests" // a variable keeps vet from catching it
+
+	logger.Info(mismatched, "many")
+	if len(reported) != 0 {
+		t.Fatalf("reported %v with CheckFormat off", reported)
+	}
+	logger.CheckFormat = true
+	logger.Info("%d of %d", 3, 4)
+	logger.WithLevel(LevelDebug).Info(mismatched, "many")
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), `format "%d requests"`) {
+		t.Errorf("reported %v, want the mismatched format", reported)
+	}
+	if want := "[Info] %!d(string=many) requests\n[Info] 3 of 4\n[Info] %!d(string=many) requests\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWaitPending(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true})
+	logger.Info("to be compressed")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 || !strings.HasSuffix(archives[0], ".gz") {
+		t.Errorf("want a single .gz archive, got %v", archives)
+	}
+}
+
+func TestSetLevelTag(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.SetLevelTag(LevelError, "ERROR:")
+
+	logger.Error("disk full")
+	logger.Info("still default")
+	if want := "ERROR: disk full\n[Info] still default\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	if LevelError.Tag() != "[Error]" {
+		t.Errorf("package tag changed to %q", LevelError.Tag())
+	}
+
+	buf.Reset()
+	other := New(&buf, "", 0, LevelDebug, nil)
+	other.Error("disk full")
+	if buf.String() != "[Error] disk full\n" {
+		t.Errorf("override leaked to another logger: %q", buf.String())
+	}
+}
+
+func TestRotateOnStart(t *testing.T) {
+	for _, previous := range []string{"", "previous run\n"} {
+		logFile := filepath.Join(t.TempDir(), "app.log")
+		ioutil.WriteFile(logFile, []byte(previous), 0644)
+		f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, RotateOnStart: true})
+		if _, err = logger.StartRotate(); err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("new run")
+		logger.Stop()
+		logger.WaitPending()
+
+		archives, _ := filepath.Glob(logFile + ".*")
+		if previous == "" {
+			if len(archives) != 0 {
+				t.Errorf("empty file archived: %v", archives)
+			}
+			continue
+		}
+		if len(archives) != 1 {
+			t.Fatalf("want one archive, got %v", archives)
+		}
+		if data, _ := ioutil.ReadFile(archives[0]); string(data) != previous {
+			t.Errorf("archive content %q", data)
+		}
+		if data, _ := ioutil.ReadFile(logFile); string(data) != "[Info] new run\n" {
+			t.Errorf("live content %q", data)
+		}
+	}
+}
+
+func TestRotateOnStartRestarts(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	for _, run := range []string{"run1", "run2", "run3"} {
+		f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, RotateOnStart: true})
+		if _, err = logger.StartRotate(); err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("%s", run)
+		logger.Stop()
+		logger.WaitPending()
+		f.Close()
+	}
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	var got []string
+	for _, fn := range archives {
+		data, _ := ioutil.ReadFile(fn)
+		got = append(got, string(data))
+	}
+	sort.Strings(got)
+	if want := "[Info] run1\n,[Info] run2\n"; strings.Join(got, ",") != want {
+		t.Errorf("archives hold %q, want %q", got, want)
+	}
+}
+
+func TestMaxMessageBytes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "prefix ", 0, LevelDebug, nil)
+	logger.Info("%s", strings.Repeat("x", 1000))
+	if buf.Len() != len("prefix [Info] ")+1000+1 {
+		t.Fatalf("truncated without MaxMessageBytes: %d bytes", buf.Len())
+	}
+
+	for _, msg := range []string{strings.Repeat("x", 1000), strings.Repeat("é", 500)} {
+		buf.Reset()
+		logger.MaxMessageBytes = 64
+		logger.Info("%s", msg)
+
+		line := buf.String()
+		if len(line) > 64 || !utf8.ValidString(line) {
+			t.Errorf("bad truncation (%d bytes): %q", len(line), line)
+		}
+		i := strings.Index(line, "...[truncated ")
+		if i < 0 {
+			t.Fatalf("missing marker: %q", line)
+		}
+		var cut int
+		fmt.Sscanf(line[i:], "...[truncated %d bytes]\n", &cut)
+		if full := len("prefix [Info] ") + len(msg); i+cut != full {
+			t.Errorf("kept %d + cut %d != %d", i, cut, full)
+		}
+	}
+
+	buf.Reset()
+	logger.MaxMessageBytes = 10
+	logger.Info("%s", strings.Repeat("é", 100))
+	if line := buf.String(); len(line) > 10 || !utf8.ValidString(line) || !strings.HasSuffix(line, "\n") {
+		t.Errorf("bad truncation below the marker size (%d bytes): %q", len(line), line)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5})
+	if logger.Writer() != &buf {
+		t.Errorf("Writer() = %v", logger.Writer())
+	}
+
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetOutput(f)
+	if logger.Writer() != f {
+		t.Errorf("Writer() = %v after SetOutput", logger.Writer())
+	}
+
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	rotated, ok := logger.Writer().(*os.File)
+	if !ok || rotated == f || rotated.Name() != logFile {
+		t.Errorf("Writer() = %v after Rotate", logger.Writer())
+	}
+	rotated.Close()
+}
+
+func TestIncludeSeq(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5})
+	logger.IncludeSeq = true
+	for i := 0; i < 3; i++ {
+		logger.Info("before")
+	}
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		logger.Info("after")
+	}
+	logger.WaitPending()
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 {
+		t.Fatalf("want one archive, got %v", archives)
+	}
+	before, _ := ioutil.ReadFile(archives[0])
+	after, _ := ioutil.ReadFile(logFile)
+
+	last := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(before)+string(after)), "\n") {
+		var seq int
+		if _, err := fmt.Sscanf(line, "[Info] seq=%d", &seq); err != nil {
+			t.Fatalf("no seq in %q", line)
+		}
+		if seq != last+1 {
+			t.Errorf("seq %d after %d", seq, last)
+		}
+		last = seq
+	}
+	if last != 6 {
+		t.Errorf("last seq %d, want 6", last)
+	}
+}
+
+func TestSkipEmpty(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, SkipEmpty: true})
+
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 0 {
+		t.Errorf("empty file archived: %v", archives)
+	}
+
+	logger.Info("busy")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 1 {
+		t.Errorf("want one archive, got %v", archives)
+	}
+}
+
+func TestRotateSkipsAfterManualRotate(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := &RotateConfig{Duration: 2 * time.Second, MaxBackups: 5}
+	logger := New(f, "", 0, LevelDebug, rc)
+	if _, err = logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("before")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Until(rc.nextPeriod(time.Now())) + 300*time.Millisecond)
+	logger.Stop()
+	logger.WaitPending()
+	logger.Writer().(io.Closer).Close()
+
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 1 {
+		t.Errorf("want only the manual archive, got %q", archives)
+	}
+}
+
+func TestStopFromLoop(t *testing.T) {
+	var (
+		logger  *Logger
+		once    sync.Once
+		stopped = make(chan struct{})
+	)
+	logger = New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{
+		Duration:      time.Hour,
+		MaxBackups:    2,
+		CheckInterval: time.Millisecond,
+		ShouldRotate: func() bool {
+			logger.Stop()
+			once.Do(func() { close(stopped) })
+			return false
+		},
+	})
+	if _, err := logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop called from ShouldRotate didn't return")
+	}
+	logger.Stop() // waits for the loop to exit
+	if _, ok := logger.NextRotation(); ok {
+		t.Error("loop still running after Stop")
+	}
+}
+
+func TestStartRotateStop(t *testing.T) {
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2})
+	stop, err := logger.StartRotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	waitNext := func() bool {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if _, ok := logger.NextRotation(); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if !waitNext() {
+		t.Fatal("loop not running")
+	}
+	stop()
+	stop()
+	if _, ok := logger.NextRotation(); ok {
+		t.Error("loop still running after stop")
+	}
+
+	// a stale stop leaves the loop started after it alone
+	again, err := logger.StartRotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if !waitNext() {
+		t.Error("an earlier stop ended the new loop")
+	}
+	logger.Stop()
+	again()
+	if _, ok := logger.NextRotation(); ok {
+		t.Error("loop still running after Stop")
+	}
+}
+
+func TestRotateTick(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, SyncMaintenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	// archives older than Duration*MaxBackups at a tick go
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Hour)
+	for i, want := range []int{1, 2, 3, 3} {
+		now := base.Add(time.Duration(i) * time.Hour)
+		logger.Info("hour %d", i)
+		if err := logger.rotateTick(now); err != nil {
+			t.Fatal(err)
+		}
+		archives, _ := logger.Archives()
+		if len(archives) != want {
+			t.Fatalf("tick %d: %d archives, want %d", i, len(archives), want)
+		}
+		if !archives[0].Time.Equal(now) || !archives[want-1].Time.Equal(base.Add(time.Duration(i+1-want)*time.Hour)) {
+			t.Errorf("tick %d: archives %+v", i, archives)
+		}
+	}
+}
+
+func TestFlushOnLevel(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	logger := New(bw, "", 0, LevelDebug, nil)
+	logger.FlushOnLevel = LevelError
+
+	logger.Info("one")
+	logger.Info("two")
+	if buf.Len() != 0 {
+		t.Fatalf("Info flushed: %q", buf.String())
+	}
+	logger.Error("three")
+	if want := "[Info] one\n[Info] two\n[Error] three\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCarryTailBytes(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, CarryTailBytes: 30})
+	for _, msg := range []string{"first line", "second line", "third line", "fourth"} {
+		logger.Info("%s", msg)
+	}
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	logger.Info("fresh")
+	logger.Writer().(io.Closer).Close()
+
+	// the last 30 bytes start inside "third line"
+	data, _ := ioutil.ReadFile(logFile)
+	if want := "[Info] fourth\n[Info] fresh\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
+
+func TestRotationRecord(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, RotationRecord: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Format = FormatLogfmt
+	logger.Info("before")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after")
+
+	archives, _ := logger.Archives()
+	if len(archives) != 1 {
+		t.Fatalf("archives %v", archives)
+	}
+	old, _ := ioutil.ReadFile(archives[0].Path)
+	data, _ := ioutil.ReadFile(logFile)
+	lines := strings.Split(string(data), "\n")
+	want := fmt.Sprintf(`level=info msg="rotated from=%s size=%d sha256=%x"`, filepath.Base(archives[0].Path), len(old), sha256.Sum256(old))
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], want) || !strings.HasSuffix(lines[1], "msg=after") {
+		t.Errorf("got %q, want the first line to end with %s", data, want)
+	}
+}
+
+func TestRotationRecordLiveGzip(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log.gz")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, LiveGzip: true, RotationRecord: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("before")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	archives, _ := logger.Archives()
+	logger.Close()
+	if len(archives) != 1 {
+		t.Fatalf("archives %v", archives)
+	}
+
+	// the digest covers the finished member, as the archive keeps it
+	old, _ := ioutil.ReadFile(archives[0].Path)
+	want := fmt.Sprintf("size=%d sha256=%x", len(old), sha256.Sum256(old))
+	if got := readGzip(t, logFile); !strings.Contains(got, want) {
+		t.Errorf("got %q, want %s", got, want)
+	}
+	if got := readGzip(t, archives[0].Path); got != "[Info] before\n" {
+		t.Errorf("archive has %q", got)
+	}
+}
+
+func TestArchiveMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no permission bits")
+	}
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, Compress: true, ArchiveMode: 0600, SyncMaintenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("archived")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	live, err := os.Stat(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archives, _ := logger.Archives()
+	if len(archives) != 1 || !strings.HasSuffix(archives[0].Path, ".gz") {
+		t.Fatalf("archives %+v", archives)
+	}
+	if fi, err := os.Stat(archives[0].Path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("archive mode %v, %v, want 0600", fi.Mode(), err)
+	}
+	if live.Mode().Perm() == 0600 {
+		t.Errorf("live file mode %v, ArchiveMode must not apply to it", live.Mode())
+	}
+}
+
+func TestCompressDirect(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	plain := regexp.MustCompile(`^app\.log\.[0-9]+$`)
+
+	var renamed []string
+	renameFile = func(from, to string) error {
+		renamed = append(renamed, filepath.Base(to))
+		return os.Rename(from, to)
+	}
+	defer func() { renameFile = os.Rename }()
+
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Second, MaxBackups: 10, Compress: true, CompressDirect: true, SyncMaintenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("archived")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range renamed {
+		if plain.MatchString(name) {
+			t.Errorf("renamed to the plain archive %s", name)
+		}
+	}
+	files, _ := ioutil.ReadDir(dir)
+	var names []string
+	for _, fi := range files {
+		names = append(names, fi.Name())
+	}
+	if len(names) != 2 || !strings.HasSuffix(names[1], ".gz") {
+		t.Fatalf("directory has %v", names)
+	}
+	if gz := readGzip(t, filepath.Join(dir, names[1])); gz != "[Info] archived\n" {
+		t.Errorf("archive has %q", gz)
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "sub", "app.log")
+	if _, err := NewFile(logFile, "", 0, LevelDebug, nil); err == nil {
+		t.Fatal("opened a file in a missing directory without MkdirAll")
+	}
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, MkdirAll: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("before")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after")
+	if err = logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = logger.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 {
+		t.Fatalf("want one archive, got %q", archives)
+	}
+	if data, _ := ioutil.ReadFile(archives[0]); string(data) != "[Info] before\n" {
+		t.Errorf("archive holds %q", data)
+	}
+	if data, _ := ioutil.ReadFile(logFile); string(data) != "[Info] after\n" {
+		t.Errorf("log file holds %q", data)
+	}
+}
+
+func TestCompressOnClose(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	for _, msg := range []string{"first run", "second run"} {
+		logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, CompressOnClose: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("%s", msg)
+		if err = logger.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(logFile); !os.IsNotExist(err) {
+			t.Errorf("log file left after Close: %v", err)
+		}
+	}
+
+	f, err := os.Open(logFile + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(zr); string(data) != "[Info] first run\n[Info] second run\n" {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestNextRotation(t *testing.T) {
+	logger, err := NewFile(filepath.Join(t.TempDir(), "app.log"), "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	if _, ok := logger.NextRotation(); ok {
+		t.Fatal("NextRotation set before StartRotate")
+	}
+
+	start := time.Now()
+	if _, err = logger.StartRotate(); err != nil {
+		t.Fatal(err)
+	}
+	var next time.Time
+	for ok := false; !ok && time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		next, ok = logger.NextRotation()
+	}
+	if !next.After(start) || next.After(start.Add(time.Hour)) || !next.Equal(next.Truncate(time.Hour)) {
+		t.Errorf("NextRotation = %v, want the next hour after %v", next, start)
+	}
+
+	logger.Stop()
+	if _, ok := logger.NextRotation(); ok {
+		t.Error("NextRotation set after Stop")
+	}
+}
+
+func TestCollision(t *testing.T) {
+	for _, c := range []struct {
+		policy Collision
+		want   []string // archives contents, sorted by name
+		fail   bool
+	}{
+		{CollisionCounter, []string{"[Info] one\n", "[Info] two\n"}, false},
+		{CollisionOverwrite, []string{"[Info] two\n"}, false},
+		{CollisionError, []string{"[Info] one\n"}, true},
+	} {
+		logFile := filepath.Join(t.TempDir(), "app.log")
+		logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, Collision: c.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("one")
+		if err = logger.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("two")
+		if err = logger.Rotate(); (err != nil) != c.fail {
+			t.Errorf("policy %d: second Rotate returned %v", c.policy, err)
+		}
+		logger.Close()
+
+		archives, _ := filepath.Glob(logFile + ".*")
+		sort.Strings(archives)
+		var got []string
+		for _, fn := range archives {
+			data, _ := ioutil.ReadFile(fn)
+			got = append(got, string(data))
+		}
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("policy %d: archives hold %q, want %q", c.policy, got, c.want)
+		}
+	}
+}
+
+func TestCollisionCounterRestart(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	now := time.Now().Truncate(time.Hour)
+	suffix := now.Format(formatMin)
+	// archives left by an earlier run, the first one since compressed
+	ioutil.WriteFile(logFile+"."+suffix+".gz", []byte("first"), 0644)
+	ioutil.WriteFile(logFile+"."+suffix+".1", []byte("second"), 0644)
+
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{
+		Duration:       time.Hour,
+		MaxBackups:     5,
+		SuffixTimeFunc: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("third")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+
+	if b, err := ioutil.ReadFile(logFile + "." + suffix + ".2"); err != nil || string(b) != "[Info] third\n" {
+		t.Errorf("next archive has %q, %v", b, err)
+	}
+	if b, _ := ioutil.ReadFile(logFile + "." + suffix + ".1"); string(b) != "second" {
+		t.Errorf("earlier archive .1 has %q", b)
+	}
+
+	// a gap left by retention doesn't reorder them
+	os.Remove(logFile + "." + suffix + ".1")
+	logger.Info("fourth")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	if b, err := ioutil.ReadFile(logFile + "." + suffix + ".3"); err != nil || string(b) != "[Info] fourth\n" {
+		t.Errorf("archive after the gap has %q, %v", b, err)
+	}
+}
+
+func TestSetOutputDuringRotate(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewFile(filepath.Join(dir, "a.log"), "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*os.File
+	for _, name := range []string{"a.log", "b.log"} {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			logger.Info("record %d", i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			logger.Rotate()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			logger.SetOutput(files[i%2])
+		}
+	}()
+	wg.Wait()
+	logger.WaitPending()
+
+	// the last SetOutput wins unless a rotation of its path followed
+	if w, ok := logger.Writer().(namedFile); !ok || w.Name() != files[1].Name() {
+		t.Errorf("output is %v, want %s", logger.Writer(), files[1].Name())
+	}
+	logger.Close()
+}
+
+func TestSetPath(t *testing.T) {
+	dir := t.TempDir()
+	oldFile, newFile := filepath.Join(dir, "old.log"), filepath.Join(dir, "new", "app.log")
+	logger, err := NewFile(oldFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MkdirAll: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	logger.Info("before")
+	if err := logger.SetPath(newFile); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after")
+	if err := logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("rotated")
+
+	if b, _ := ioutil.ReadFile(oldFile); string(b) != "[Info] before\n" {
+		t.Errorf("old file has %q", b)
+	}
+	if b, _ := ioutil.ReadFile(newFile); string(b) != "[Info] rotated\n" {
+		t.Errorf("new file has %q", b)
+	}
+	if archives, _ := filepath.Glob(newFile + ".*"); len(archives) != 1 {
+		t.Errorf("archives of the new path: %v", archives)
+	}
+	if archives, _ := filepath.Glob(oldFile + ".*"); len(archives) != 0 {
+		t.Errorf("old file must not be archived: %v", archives)
+	}
+}
+
+func TestSyncMaintenance(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	expired := logFile + "." + time.Now().Add(-3*time.Hour).Format(formatMin) + ".gz"
+	ioutil.WriteFile(expired, nil, 0644)
+
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 1, Compress: true, SyncMaintenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("done")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// no WaitPending
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 || !strings.HasSuffix(archives[0], ".gz") || archives[0] == expired {
+		t.Fatalf("want only the new .gz archive, got %q", archives)
+	}
+	if got := readGzip(t, archives[0]); got != "[Info] done\n" {
+		t.Errorf("archive holds %q", got)
+	}
+}
+
+func TestCompressDelay(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, CompressDelay: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	for i := 0; i < 4; i++ {
+		logger.Info("record %d", i)
+		if err = logger.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		logger.WaitPending()
+	}
+
+	archives, err := logger.Archives()
+	if err != nil || len(archives) != 4 {
+		t.Fatalf("got %+v, %v", archives, err)
+	}
+	for i, a := range archives {
+		if a.Compressed != (i >= 2) {
+			t.Errorf("archive %d %s compressed %v", i, filepath.Base(a.Path), a.Compressed)
+		}
+	}
+	if data, _ := ioutil.ReadFile(archives[0].Path); string(data) != "[Info] record 3\n" {
+		t.Errorf("newest archive holds %q", data)
+	}
+	if got := readGzip(t, archives[3].Path); got != "[Info] record 0\n" {
+		t.Errorf("oldest archive holds %q", got)
+	}
 }
@@ -0,0 +1,72 @@
+package rotatelog
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// formatLogfmt renders a record as `ts=... level=info msg="..."`, with a
+// caller key when the flags ask for the file and the fields after msg, for
+// FormatLogfmt.
+func (l *Logger) formatLogfmt(calldepth int, level Level, s string, fields []Field) []byte {
+	var (
+		flag = l.Flags()
+		b    = make([]byte, 0, 64+len(s))
+	)
+	if l.SchemaVersion > 0 {
+		b = appendLogfmt(b, "v", strconv.Itoa(l.SchemaVersion))
+	}
+	b = appendLogfmt(b, "ts", l.now(flag).Format(time.RFC3339Nano))
+	b = appendLogfmt(b, "level", level.name())
+	if flag&(log.Lshortfile|log.Llongfile) != 0 {
+		b = appendLogfmt(b, "caller", callerOf(calldepth, flag))
+	}
+	if prefix := strings.TrimSpace(l.Prefix()); prefix != "" {
+		b = appendLogfmt(b, "prefix", prefix)
+	}
+	b = appendLogfmt(b, "msg", s)
+	for _, f := range fields {
+		b = appendLogfmt(b, f.Key, f.structured())
+	}
+	return append(b, '\n')
+}
+
+// appendLogfmt appends key=value to b, space separated from what b holds.
+// Empty values and values with spaces, quotes, '=' or control characters
+// are quoted.
+func appendLogfmt(b []byte, key, value string) []byte {
+	if len(b) > 0 {
+		b = append(b, ' ')
+	}
+	b = append(b, key...)
+	b = append(b, '=')
+	if needsQuote(value) {
+		return strconv.AppendQuote(b, value)
+	}
+	return append(b, value...)
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == '"' || r == '=' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the lower case name of the level, as NewLevel takes it.
+func (l Level) name() string {
+	for name, level := range levelNames {
+		if level == l {
+			return name
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,45 @@
+package rotatelog
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestFormatLogfmt(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.Format = FormatLogfmt
+
+	logger.Warning(`disk "data" at %d%%`, 90)
+	re := regexp.MustCompile(`^ts=\S+ level=warning msg="disk \\"data\\" at 90%"\n$`)
+	if !re.MatchString(buf.String()) {
+		t.Errorf("got %q", buf.String())
+	}
+
+	for _, c := range []struct{ value, want string }{
+		{"plain", `k=plain`},
+		{"two words", `k="two words"`},
+		{`say "hi"`, `k="say \"hi\""`},
+		{"a=b", `k="a=b"`},
+		{"", `k=""`},
+	} {
+		if got := string(appendLogfmt(nil, "k", c.value)); got != c.want {
+			t.Errorf("appendLogfmt(%q) = %s, want %s", c.value, got, c.want)
+		}
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.SchemaVersion = 2
+
+	logger.Info("text")
+	logger.Format = FormatLogfmt
+	logger.Info("logfmt")
+	re := regexp.MustCompile(`^\[Info\] text\nv=2 ts=\S+ level=info msg=logfmt\n$`)
+	if !re.MatchString(buf.String()) {
+		t.Errorf("got %q", buf.String())
+	}
+}
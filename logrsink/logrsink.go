@@ -0,0 +1,102 @@
+// Package logrsink adapts a rotatelog.Logger to logr, keeping the go-logr
+// dependency out of the rotatelog package.
+package logrsink
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dark-wing/rotatelog"
+	"github.com/go-logr/logr"
+)
+
+// logrSink adapts a Logger to logr.LogSink.
+type logrSink struct {
+	l      *rotatelog.Logger
+	name   string
+	values []interface{}
+	depth  int
+}
+
+// New returns a logr.LogSink writing through l.
+// V(0) maps to LevelInfo, any higher verbosity to LevelDebug,
+// and logr's Error to LevelError.
+func New(l *rotatelog.Logger) logr.LogSink {
+	return &logrSink{l: l}
+}
+
+func (s *logrSink) Init(info logr.RuntimeInfo) {
+	s.depth = info.CallDepth
+}
+
+func (s *logrSink) level(v int) rotatelog.Level {
+	if v > 0 {
+		return rotatelog.LevelDebug
+	}
+	return rotatelog.LevelInfo
+}
+
+func (s *logrSink) Enabled(v int) bool {
+	return s.l.Enabled(s.level(v))
+}
+
+func (s *logrSink) Info(v int, msg string, keysAndValues ...interface{}) {
+	level := s.level(v)
+	if !s.l.Enabled(level) {
+		return
+	}
+	s.l.OutputLevel(s.depth+2, level, s.render(msg, keysAndValues))
+}
+
+func (s *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if !s.l.Enabled(rotatelog.LevelError) {
+		return
+	}
+	kv := append([]interface{}{"error", err}, keysAndValues...)
+	s.l.OutputLevel(s.depth+2, rotatelog.LevelError, s.render(msg, kv))
+}
+
+func (s *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	c := *s
+	c.values = append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &c
+}
+
+func (s *logrSink) WithName(name string) logr.LogSink {
+	c := *s
+	if c.name != "" {
+		c.name += "/" + name
+	} else {
+		c.name = name
+	}
+	return &c
+}
+
+func (s *logrSink) WithCallDepth(depth int) logr.LogSink {
+	c := *s
+	c.depth += depth
+	return &c
+}
+
+// render formats msg as "name: msg k1=v1 k2=v2".
+func (s *logrSink) render(msg string, keysAndValues []interface{}) string {
+	var b strings.Builder
+	if s.name != "" {
+		b.WriteString(s.name)
+		b.WriteString(": ")
+	}
+	b.WriteString(msg)
+	writeKV(&b, s.values)
+	writeKV(&b, keysAndValues)
+	return b.String()
+}
+
+func writeKV(b *strings.Builder, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		var v interface{} = "<no-value>"
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		fmt.Fprintf(b, " %v=%v", kv[i], v)
+	}
+}
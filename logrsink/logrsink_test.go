@@ -0,0 +1,66 @@
+package logrsink
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/dark-wing/rotatelog"
+	"github.com/go-logr/logr"
+)
+
+func TestLogrSink(t *testing.T) {
+	var buf bytes.Buffer
+	l := rotatelog.New(&buf, "", 0, rotatelog.LevelInfo, nil)
+	lr := logr.New(New(l)).WithName("db").WithValues("conn", 1)
+
+	lr.Info("connected", "host", "localhost")
+	lr.V(1).Info("verbose, should not see this")
+	lr.Error(errors.New("boom"), "query failed", "table", "users")
+
+	out := buf.String()
+	if !strings.Contains(out, "[Info] db: connected conn=1 host=localhost\n") {
+		t.Errorf("missing info line: %q", out)
+	}
+	if strings.Contains(out, "verbose") {
+		t.Errorf("V(1) should be gated at LevelInfo: %q", out)
+	}
+	if !strings.Contains(out, "[Error] db: query failed conn=1 error=boom table=users\n") {
+		t.Errorf("missing error line: %q", out)
+	}
+
+	buf.Reset()
+	l.SetLevel(rotatelog.LevelDebug)
+	if !lr.V(1).Enabled() {
+		t.Fatal("V(1) should be enabled at LevelDebug")
+	}
+	lr.V(1).Info("verbose")
+	if !strings.Contains(buf.String(), "[Debug] db: verbose") {
+		t.Errorf("missing debug line: %q", buf.String())
+	}
+
+	buf.Reset()
+	l.SetLevel(rotatelog.LevelCritical)
+	lr.Error(errors.New("boom"), "dropped")
+	if buf.Len() != 0 {
+		t.Errorf("error should be gated at LevelCritical: %q", buf.String())
+	}
+}
+
+func TestLogrSinkCaller(t *testing.T) {
+	var buf bytes.Buffer
+	lr := logr.New(New(rotatelog.New(&buf, "", log.Lshortfile, rotatelog.LevelInfo, nil)))
+	lr.Info("here")
+	lr.Error(nil, "there")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q", buf.String())
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "logrsink_test.go:") {
+			t.Errorf("caller should be the test: %q", line)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package rotatelog
+
+import "fmt"
+
+// mirror writes p to the Mirror, keeping an error it doesn't handle itself
+// for report.
+func (l *Logger) mirror(p []byte) {
+	_, err := l.Mirror.writeRaw(p)
+	if nil == err || err == errDiskFull || isDiskFull(err) {
+		return
+	}
+	l.mu.Lock()
+	l.mirrorErr = fmt.Errorf("mirror: %v", err)
+	l.mu.Unlock()
+}
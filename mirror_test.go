@@ -0,0 +1,49 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		out      = &flakyWriter{}
+		reported []error
+	)
+	mirror, err := NewFile(filepath.Join(dir, "mirror.log"), "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mirror.Close()
+	logger := New(out, "", 0, LevelDebug, nil)
+	logger.Mirror = mirror
+	logger.ErrorHandler = func(err error) { reported = append(reported, err) }
+
+	logger.Info("both")
+	out.broken = true
+	logger.Info("mirror only")
+	out.broken = false
+	if err = mirror.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	archives, _ := mirror.Archives()
+	if len(archives) != 1 {
+		t.Fatalf("mirror archives %v", archives)
+	}
+	if b, _ := ioutil.ReadFile(archives[0].Path); string(b) != "[Info] both\n[Info] mirror only\n" {
+		t.Errorf("mirror got %q", b)
+	}
+
+	mirror.SetOutput(&flakyWriter{broken: true})
+	logger.Info("primary only")
+	if got := out.buf.String(); got != "[Info] both\n[Info] primary only\n" {
+		t.Errorf("primary got %q", got)
+	}
+	if len(reported) != 1 || !strings.HasPrefix(reported[0].Error(), "mirror: device gone") {
+		t.Errorf("ErrorHandler got %v, want the mirror failure", reported)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package rotatelog
+
+import (
+	"io"
+	"os"
+)
+
+// OpenMmap opens path for appending. mmap is not supported on this
+// platform, so it is a regular file.
+func OpenMmap(path string) (io.WriteCloser, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
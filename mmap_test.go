@@ -0,0 +1,79 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package rotatelog
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMmapRotate(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	w, err := OpenMmap(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := New(w, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Mmap: true})
+	var before, after strings.Builder
+	// enough to grow the mapping past its first chunk
+	for i := 0; i < 40000; i++ {
+		logger.Info("before rotation %d", i)
+		fmt.Fprintf(&before, "[Info] before rotation %d\n", i)
+	}
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		logger.Info("after rotation %d", i)
+		fmt.Fprintf(&after, "[Info] after rotation %d\n", i)
+	}
+	if err = logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err = logger.Writer().(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 {
+		t.Fatalf("want one archive, got %v", archives)
+	}
+	if data, _ := ioutil.ReadFile(archives[0]); string(data) != before.String() {
+		t.Errorf("archive content differs: %d bytes, want %d", len(data), before.Len())
+	}
+	if data, _ := ioutil.ReadFile(logFile); string(data) != after.String() {
+		t.Errorf("live content differs: %q", data)
+	}
+}
+
+func TestMmapReopenUnclosed(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	w, err := OpenMmap(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("first run\n"))
+	if err = w.(interface{ Sync() error }).Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// no Close, as after a crash: the file keeps its zero padding
+
+	w2, err := OpenMmap(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2.Write([]byte("second run\n"))
+	if err = w2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(logFile); string(data) != "first run\nsecond run\n" {
+		t.Errorf("got %q", data)
+	}
+}
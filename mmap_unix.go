@@ -0,0 +1,125 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package rotatelog
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// mmapChunk is the step the mapping and the file grow by.
+const mmapChunk = 1 << 20
+
+type mmapFile struct {
+	mu   sync.Mutex
+	f    *os.File
+	data []byte
+	off  int
+}
+
+// OpenMmap opens path for appending through a shared memory mapping, so
+// writes are plain memory copies without a syscall per record. The file is
+// grown in 1MiB steps, readers see zero bytes past the written data until
+// it is closed and trimmed. Reopening an untrimmed file drops its trailing
+// zero bytes. Where mmap is unavailable it falls back to a regular file.
+func OpenMmap(path string) (io.WriteCloser, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if nil != err {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if nil != err {
+		f.Close()
+		return nil, err
+	}
+
+	m := &mmapFile{f: f, off: int(fi.Size())}
+	if err = m.remap(m.off); nil != err {
+		f.Close()
+		return nil, err
+	}
+	// a file that wasn't closed, e.g. after a crash, still has its zero
+	// padding, resume right after the data
+	for m.off > 0 && m.data[m.off-1] == 0 {
+		m.off--
+	}
+	return m, nil
+}
+
+// remap grows the file and the mapping to hold at least need bytes.
+func (m *mmapFile) remap(need int) error {
+	size := (need/mmapChunk + 1) * mmapChunk
+	if m.data != nil {
+		if err := syscall.Munmap(m.data); nil != err {
+			return err
+		}
+		m.data = nil
+	}
+	if err := m.f.Truncate(int64(size)); nil != err {
+		return err
+	}
+	data, err := syscall.Mmap(int(m.f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if nil != err {
+		return err
+	}
+	m.data = data
+	return nil
+}
+
+func (m *mmapFile) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return 0, os.ErrClosed
+	}
+	if m.off+len(p) > len(m.data) {
+		if err := m.remap(m.off + len(p)); nil != err {
+			return 0, err
+		}
+	}
+	copy(m.data[m.off:], p)
+	m.off += len(p)
+	return len(p), nil
+}
+
+// Sync flushes the dirty pages of the mapping to disk.
+func (m *mmapFile) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return os.ErrClosed
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&m.data[0])), uintptr(len(m.data)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return m.f.Sync()
+}
+
+func (m *mmapFile) Name() string {
+	return m.f.Name()
+}
+
+func (m *mmapFile) closeBeforeExit() {}
+
+// Close unmaps the file and trims it to the written size.
+func (m *mmapFile) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return os.ErrClosed
+	}
+	err := syscall.Munmap(m.data)
+	m.data = nil
+	if terr := m.f.Truncate(int64(m.off)); nil == err {
+		err = terr
+	}
+	if cerr := m.f.Close(); nil == err {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package rotatelog
+
+import "time"
+
+// notifyBuffer is how many events a Notify channel holds before later ones
+// are dropped for it.
+const notifyBuffer = 16
+
+// RotateEvent describes a rotation: the log file Path was archived as
+// Archive, before compression, at Time.
+type RotateEvent struct {
+	Path    string
+	Archive string
+	Time    time.Time
+}
+
+// Notify returns a channel receiving an event after each rotation. Each
+// call subscribes a new channel; one that isn't drained drops events
+// rather than holding up rotation. Close closes the channels.
+func (l *Logger) Notify() <-chan RotateEvent {
+	l = l.base()
+	ch := make(chan RotateEvent, notifyBuffer)
+	l.mu.Lock()
+	l.notify = append(l.notify, ch)
+	l.mu.Unlock()
+	return ch
+}
+
+// notifyRotate sends e to the Notify channels with room for it.
+func (l *Logger) notifyRotate(e RotateEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ch := range l.notify {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// closeNotify closes the Notify channels.
+func (l *Logger) closeNotify() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ch := range l.notify {
+		close(ch)
+	}
+	l.notify = nil
+}
@@ -0,0 +1,47 @@
+package rotatelog
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := logger.Notify(), logger.WithLevel(LevelInfo).Notify()
+
+	before := time.Now()
+	logger.Info("rotated")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	archives, _ := logger.Archives()
+	if len(archives) != 1 {
+		t.Fatalf("archives %v", archives)
+	}
+	for _, ch := range []<-chan RotateEvent{first, second} {
+		select {
+		case e := <-ch:
+			if e.Path != logFile || e.Archive != archives[0].Path || e.Time.Before(before) {
+				t.Errorf("got %+v, want %s archived as %s", e, logFile, archives[0].Path)
+			}
+		default:
+			t.Error("no event after Rotate")
+		}
+	}
+
+	// an undrained subscriber doesn't hold up rotation
+	for i := 0; i < notifyBuffer+2; i++ {
+		logger.Rotate()
+	}
+	if n := len(first); n != notifyBuffer {
+		t.Errorf("%d events buffered, want %d", n, notifyBuffer)
+	}
+	logger.Close()
+	for range first {
+	}
+}
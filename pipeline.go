@@ -0,0 +1,81 @@
+package rotatelog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ArchiveStage is a step of RotateConfig.Pipeline: Wrap returns a writer
+// processing what is written to it into w, such as a compressor, and Ext
+// is appended to the archive name, e.g. "gz".
+type ArchiveStage struct {
+	Ext  string
+	Wrap func(w io.Writer) io.WriteCloser
+}
+
+// pipelineExt returns the extension the Pipeline stages add, without dot.
+func (rc *RotateConfig) pipelineExt() string {
+	var exts []string
+	for _, stage := range rc.Pipeline {
+		if stage.Ext != "" {
+			exts = append(exts, stage.Ext)
+		}
+	}
+	return strings.Join(exts, ".")
+}
+
+// runPipeline writes path through the Pipeline stages, in order, into
+// path.<extensions>, removing path once every stage has succeeded.
+func (l *Logger) runPipeline(path string) (err error) {
+	out := fmt.Sprintf("%s.%s", path, l.rotateCfg.pipelineExt())
+
+	in, err := os.Open(path)
+	if nil != err {
+		l.Error("open file for pipeline err:%s", err.Error())
+		return
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if nil != err {
+		return
+	}
+
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if nil != err {
+		l.Error("open file for pipeline err:%s", err.Error())
+		return
+	}
+	// the first stage gets the data, each writes into the next
+	var (
+		w      io.Writer = f
+		stages           = make([]io.WriteCloser, len(l.rotateCfg.Pipeline))
+	)
+	for i := len(stages) - 1; i >= 0; i-- {
+		stages[i] = l.rotateCfg.Pipeline[i].Wrap(w)
+		w = stages[i]
+	}
+
+	_, err = io.Copy(w, in)
+	for _, stage := range stages {
+		if cerr := stage.Close(); nil == err {
+			err = cerr
+		}
+	}
+	if cerr := f.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		l.Error("archive pipeline %s err:%s", path, err.Error())
+		os.Remove(out)
+		return
+	}
+
+	os.Chtimes(out, fi.ModTime(), fi.ModTime())
+	in.Close()
+	if len(l.rotateCfg.EncryptKey) > 0 {
+		return wipeFile(path)
+	}
+	return os.Remove(path)
+}
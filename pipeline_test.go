@@ -0,0 +1,66 @@
+package rotatelog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// xorWriter is a dummy cipher, xor is its own inverse.
+type xorWriter struct {
+	w   io.Writer
+	key byte
+}
+
+func (x xorWriter) Write(p []byte) (int, error) {
+	q := make([]byte, len(p))
+	for i, b := range p {
+		q[i] = b ^ x.key
+	}
+	return x.w.Write(q)
+}
+
+func (x xorWriter) Close() error {
+	return nil
+}
+
+func TestPipeline(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 2, Pipeline: []ArchiveStage{
+		{"gz", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"xor", func(w io.Writer) io.WriteCloser { return xorWriter{w, 0x5a} }},
+	}}
+	logger, err := NewFile(logFile, "", 0, LevelDebug, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	logger.Info("piped")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 || filepath.Ext(archives[0]) != ".xor" || filepath.Ext(archives[0][:len(archives[0])-4]) != ".gz" {
+		t.Fatalf("want one .gz.xor archive, got %q", archives)
+	}
+	data, _ := ioutil.ReadFile(archives[0])
+	var plain bytes.Buffer
+	xorWriter{&plain, 0x5a}.Write(data)
+	zr, err := gzip.NewReader(&plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadAll(zr); string(got) != "[Info] piped\n" {
+		t.Errorf("archive holds %q", got)
+	}
+	if list, _ := logger.Archives(); len(list) != 1 {
+		t.Errorf("Archives lists %v", list)
+	}
+}
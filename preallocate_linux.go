@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package rotatelog
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize is FALLOC_FL_KEEP_SIZE, reserving blocks past the end
+// without changing the size.
+const fallocKeepSize = 0x1
+
+// preallocate reserves size bytes of disk for the empty file f. Volumes
+// without fallocate support are left alone, a full one fails.
+func preallocate(f *os.File, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocKeepSize, 0, size)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+//go:build linux
+// +build linux
+
+package rotatelog
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func allocated(t *testing.T, path string) (size, blocks int64) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Size(), fi.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+func TestPreallocate(t *testing.T) {
+	const reserve = 1 << 20
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2, Preallocate: reserve})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	size, blocks := allocated(t, logFile)
+	if size != 0 {
+		t.Fatalf("preallocation changed the size to %d", size)
+	}
+	if blocks < reserve {
+		t.Skipf("volume doesn't support fallocate, %d bytes allocated", blocks)
+	}
+
+	logger.Info("one")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if size, blocks = allocated(t, logFile); size != 0 || blocks < reserve {
+		t.Errorf("rotated file has size %d and %d bytes allocated", size, blocks)
+	}
+}
@@ -0,0 +1,99 @@
+package rotatelog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CompressFormat is an archive format RecompressAll converts to: NewWriter
+// compresses into w and Ext, without dot, names the result.
+type CompressFormat struct {
+	Ext       string
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// GzipFormat is the format of Compress.
+var GzipFormat = CompressFormat{
+	Ext:       "gz",
+	NewWriter: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+}
+
+// RecompressAll converts the uncompressed and gzip archives of the log
+// file to format, keeping their modification times. Each is written to a
+// temporary file renamed into place before the old one is removed.
+// Archives already in format, encrypted, ContentAddressed or compressed
+// otherwise are left alone. Configure the same extension for new archives, with
+// CompressCommand and CompressExt or a Pipeline, so that cleanup
+// recognises the converted ones.
+func (l *Logger) RecompressAll(format CompressFormat) error {
+	archives, err := l.Archives()
+	if nil != err {
+		return err
+	}
+	for _, a := range archives {
+		if a.Encrypted || a.Content != "" || strings.HasSuffix(a.Path, "."+format.Ext) {
+			continue
+		}
+		if a.Compressed && !strings.HasSuffix(a.Path, ".gz") {
+			continue
+		}
+		if err = l.recompress(a, format); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// recompress converts the archive a to format.
+func (l *Logger) recompress(a ArchiveInfo, format CompressFormat) (err error) {
+	var (
+		stem = a.Path
+		src  io.Reader
+	)
+	f, err := os.Open(a.Path)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if nil != err {
+		return
+	}
+	src = f
+	if a.Compressed {
+		stem = strings.TrimSuffix(a.Path, ".gz")
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(f); nil != err {
+			return fmt.Errorf("recompress %s: %v", a.Path, err)
+		}
+		src = zr
+	}
+
+	out := fmt.Sprintf("%s.%s", stem, format.Ext)
+	tmp := out + ".tmp"
+	wf, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if nil != err {
+		return
+	}
+	zw := format.NewWriter(wf)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); nil == err {
+		err = cerr
+	}
+	if cerr := wf.Close(); nil == err {
+		err = cerr
+	}
+	if nil == err {
+		os.Chtimes(tmp, fi.ModTime(), fi.ModTime())
+		err = os.Rename(tmp, out)
+	}
+	if nil != err {
+		os.Remove(tmp)
+		return fmt.Errorf("recompress %s: %v", a.Path, err)
+	}
+	f.Close()
+	return os.Remove(a.Path)
+}
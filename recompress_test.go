@@ -0,0 +1,58 @@
+package rotatelog
+
+import (
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecompressAll(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		logFile = filepath.Join(dir, "app.log")
+		stamp   = time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+		gz      = logFile + "." + stamp.Format(formatMin)
+		plain   = logFile + "." + stamp.Add(time.Hour).Format(formatMin)
+	)
+	writeGzip(t, gz+".gz", "[Info] zipped\n")
+	ioutil.WriteFile(plain, []byte("[Info] plain\n"), 0644)
+	os.Chtimes(gz+".gz", stamp, stamp)
+
+	zz := CompressFormat{Ext: "zz", NewWriter: func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }}
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, CompressCommand: []string{"zlib-flate", "-compress"}, CompressExt: "zz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	if err = logger.RecompressAll(zz); err != nil {
+		t.Fatal(err)
+	}
+
+	for fn, want := range map[string]string{gz: "[Info] zipped\n", plain: "[Info] plain\n"} {
+		f, err := os.Open(fn + ".zz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zlib.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := ioutil.ReadAll(zr); string(got) != want {
+			t.Errorf("%s.zz holds %q, want %q", fn, got, want)
+		}
+		f.Close()
+	}
+	if fi, err := os.Stat(gz + ".zz"); err != nil || !fi.ModTime().Equal(stamp) {
+		t.Errorf("modification time not kept: %v", err)
+	}
+	if archives, _ := filepath.Glob(logFile + ".*"); len(archives) != 2 {
+		t.Errorf("old archives left: %q", archives)
+	}
+	if list, _ := logger.Archives(); len(list) != 2 || !list[0].Compressed || !list[1].Compressed {
+		t.Errorf("Archives lists %+v", list)
+	}
+}
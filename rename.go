@@ -0,0 +1,62 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// maxHeld bounds the records held while the log file is closed.
+const maxHeld = 4 << 20
+
+var (
+	// renameFile and renameBusy are the file system calls of renameLog.
+	renameFile = os.Rename
+	renameBusy = isRenameBusy
+)
+
+// heldRecords keeps the records written while the log file is closed for
+// renameLog, swapOutput writes them to the next file.
+type heldRecords struct {
+	bytes.Buffer
+}
+
+func (h *heldRecords) Write(p []byte) (int, error) {
+	if h.Len()+len(p) > maxHeld {
+		return 0, io.ErrShortWrite
+	}
+	return h.Buffer.Write(p)
+}
+
+// renameLog renames the log file from to to. Where an open file can't be
+// renamed, as on Windows, the output is closed for the rename and records
+// are held meanwhile. If the rename still fails from is reopened.
+func (l *Logger) renameLog(from, to string) error {
+	err := renameFile(from, to)
+	if nil == err || !renameBusy(err) {
+		return err
+	}
+
+	l.mu.Lock()
+	c, ok := l.w.(io.Closer)
+	if ok {
+		l.w = &heldRecords{}
+	}
+	l.mu.Unlock()
+	if !ok {
+		return err
+	}
+	c.Close()
+	if err = renameFile(from, to); nil != err {
+		l.reopen()
+	}
+	return err
+}
+
+// holding reports whether records are held by renameLog.
+func (l *Logger) holding() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.w.(*heldRecords)
+	return ok
+}
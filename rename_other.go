@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package rotatelog
+
+// isRenameBusy is false where open files can be renamed.
+func isRenameBusy(err error) bool {
+	return false
+}
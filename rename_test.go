@@ -0,0 +1,54 @@
+package rotatelog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenameBusy(t *testing.T) {
+	errBusy := errors.New("sharing violation")
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	// like Windows, refuse to rename the file while it is open
+	renames := 0
+	renameFile = func(from, to string) error {
+		renames++
+		if _, open := logger.Writer().(*os.File); open {
+			return errBusy
+		}
+		logger.Info("during")
+		return os.Rename(from, to)
+	}
+	renameBusy = func(err error) bool { return err == errBusy }
+	defer func() { renameFile, renameBusy = os.Rename, isRenameBusy }()
+
+	logger.Info("before")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after")
+	logger.WaitPending()
+
+	if renames != 2 {
+		t.Errorf("renamed %d times, want 2", renames)
+	}
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 {
+		t.Fatalf("want one archive, got %q", archives)
+	}
+	if data, _ := ioutil.ReadFile(archives[0]); string(data) != "[Info] before\n" {
+		t.Errorf("archive holds %q", data)
+	}
+	if data, _ := ioutil.ReadFile(logFile); string(data) != "[Info] during\n[Info] after\n" {
+		t.Errorf("log file holds %q", data)
+	}
+}
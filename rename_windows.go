@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package rotatelog
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+)
+
+// isRenameBusy reports a rename refused because the file is open.
+func isRenameBusy(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorAccessDenied)
+}
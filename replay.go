@@ -0,0 +1,101 @@
+package rotatelog
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// MergeArchives returns the records of the archives of the log file base in
+// dir, oldest first, gzip ones decompressed on the fly. Archives are found
+// by their name as rotation gives it, with a minute, second or NanoSuffix
+// suffix; encrypted ones are skipped. The live file is not included, chain
+// it with io.MultiReader if needed.
+func MergeArchives(dir, base string) (io.ReadCloser, error) {
+	var archives []ArchiveInfo
+	// one scan per suffix layout, see suffixFormat
+	for _, rc := range []RotateConfig{
+		{Duration: time.Hour},
+		{Duration: time.Second},
+		{NanoSuffix: true},
+	} {
+		rc.ContentAddressed = true
+		l := New(ioutil.Discard, "", 0, LevelDebug, &rc)
+		found, err := l.scanArchives(filepath.Join(dir, base))
+		if nil != err {
+			return nil, err
+		}
+		// oldest first, keeping the collision counter order of a period
+		for i := len(found) - 1; i >= 0; i-- {
+			if !found[i].Encrypted {
+				archives = append(archives, found[i])
+			}
+		}
+	}
+	sort.SliceStable(archives, func(i, j int) bool {
+		return archives[i].Time.Before(archives[j].Time)
+	})
+	return &archiveReader{archives: archives}, nil
+}
+
+// archiveReader reads archives one after the other.
+type archiveReader struct {
+	archives []ArchiveInfo
+	f        *os.File
+	r        io.Reader
+}
+
+func (ar *archiveReader) Read(p []byte) (int, error) {
+	for {
+		if ar.r == nil {
+			if len(ar.archives) == 0 {
+				return 0, io.EOF
+			}
+			if err := ar.next(); nil != err {
+				return 0, err
+			}
+		}
+		n, err := ar.r.Read(p)
+		if err == io.EOF {
+			ar.Close()
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// next opens the oldest archive left.
+func (ar *archiveReader) next() (err error) {
+	a := ar.archives[0]
+	ar.archives = ar.archives[1:]
+	path := a.Path
+	if a.Content != "" {
+		path = a.Content
+	}
+	if ar.f, err = os.Open(path); nil != err {
+		return
+	}
+	ar.r = ar.f
+	if a.Compressed {
+		if ar.r, err = gzip.NewReader(ar.f); nil != err {
+			ar.Close()
+		}
+	}
+	return
+}
+
+// Close closes the archive being read.
+func (ar *archiveReader) Close() (err error) {
+	if ar.f != nil {
+		err = ar.f.Close()
+	}
+	ar.f, ar.r = nil, nil
+	return
+}
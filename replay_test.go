@@ -0,0 +1,41 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeArchives(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.log.202610161000.gz":             "10:00\n",
+		"app.log.202610161100":                "11:00\n",
+		"app.log.202610161100.1.gz":           "11:00 again\n",
+		"app.log.202610161200.enc":            "sealed\n",
+		"app.log.20261016123000":              "12:30\n",
+		"app.log.20261016123000,000000500.gz": "12:30 burst\n",
+		"app.log":                             "live\n",
+		"other.log.202610160900":              "other\n",
+	} {
+		path := filepath.Join(dir, name)
+		if filepath.Ext(name) == ".gz" {
+			writeGzip(t, path, content)
+		} else {
+			ioutil.WriteFile(path, []byte(content), 0644)
+		}
+	}
+
+	r, err := MergeArchives(dir, "app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "10:00\n11:00\n11:00 again\n12:30\n12:30 burst\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
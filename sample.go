@@ -0,0 +1,47 @@
+package rotatelog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Sample thins out the records of a level: of those logged in each Tick
+// (default a second) the First are kept, then every Thereafter-th, none
+// if Thereafter is 0. The state kept is one counter per level, whatever
+// the messages or call sites.
+type Sample struct {
+	First      int
+	Thereafter int
+	Tick       time.Duration
+}
+
+type sampleCounter struct {
+	n    uint64 // records in the current tick
+	tick int64  // current tick number
+}
+
+// sample reports whether a record at level passes Sampling, counting the
+// ones it drops.
+func (l *Logger) sample(level Level) bool {
+	s, ok := l.Sampling[level]
+	if !ok || level < LevelDebug || level > LevelCritical {
+		return true
+	}
+	tick := s.Tick
+	if tick <= 0 {
+		tick = time.Second
+	}
+
+	root := l.base()
+	c := &root.samples[level]
+	now := time.Now().UnixNano() / int64(tick)
+	if old := atomic.LoadInt64(&c.tick); old != now && atomic.CompareAndSwapInt64(&c.tick, old, now) {
+		atomic.StoreUint64(&c.n, 0)
+	}
+	n := atomic.AddUint64(&c.n, 1)
+	if n <= uint64(s.First) || (s.Thereafter > 0 && (n-uint64(s.First))%uint64(s.Thereafter) == 0) {
+		return true
+	}
+	atomic.AddUint64(&root.dropSampled, 1)
+	return false
+}
@@ -0,0 +1,56 @@
+package rotatelog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSampling(t *testing.T) {
+	var buf syncBuffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.Sampling = map[Level]Sample{
+		LevelDebug: {First: 2, Thereafter: 10, Tick: time.Hour},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				logger.Debug("debug")
+				logger.Error("error")
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := buf.String()
+	// 100 Debug records: the first 2, then the 12th, 22nd up to the 92nd
+	if n := strings.Count(out, "[Debug]"); n != 11 {
+		t.Errorf("%d Debug records kept, want 11", n)
+	}
+	if n := strings.Count(out, "[Error]"); n != 100 {
+		t.Errorf("%d Error records kept, want 100", n)
+	}
+	if got := logger.Stats().Sampled; got != 89 {
+		t.Errorf("Stats().Sampled = %d, want 89", got)
+	}
+}
+
+func TestSamplingTick(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.Sampling = map[Level]Sample{LevelInfo: {First: 1, Tick: 50 * time.Millisecond}}
+
+	logger.Info("kept")
+	logger.Info("dropped")
+	time.Sleep(60 * time.Millisecond)
+	logger.Info("next tick")
+	if got := buf.String(); got != "[Info] kept\n[Info] next tick\n" {
+		t.Errorf("got %q", got)
+	}
+}
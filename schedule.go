@@ -0,0 +1,57 @@
+package rotatelog
+
+import "time"
+
+const day = 24 * time.Hour
+
+// RotateDaily returns a config rotating at local midnight and keeping
+// maxBackups days of archives.
+func RotateDaily(maxBackups int) *RotateConfig {
+	return &RotateConfig{MaxBackups: maxBackups, Duration: day, LocalTime: true}
+}
+
+// RotateHourly returns a config rotating on local hour boundaries and
+// keeping maxBackups hours of archives.
+func RotateHourly(maxBackups int) *RotateConfig {
+	return &RotateConfig{MaxBackups: maxBackups, Duration: time.Hour, LocalTime: true}
+}
+
+// localAligned reports whether periods follow the civil day of t's location.
+func (rc *RotateConfig) localAligned() bool {
+	return rc.LocalTime && rc.Duration > 0 && rc.Duration <= day && day%rc.Duration == 0
+}
+
+func midnight(t time.Time, days int) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, t.Location())
+}
+
+// periodStart returns the start of the rotation period holding t.
+// time.Truncate counts from the zero time, so without LocalTime a day
+// starts at UTC midnight.
+func (rc *RotateConfig) periodStart(t time.Time) time.Time {
+	if rc.Cron != "" {
+		return t.Truncate(time.Minute)
+	}
+	if !rc.localAligned() {
+		return t.Truncate(rc.Duration)
+	}
+	start := midnight(t, 0)
+	if rc.Duration == day {
+		return start
+	}
+	return start.Add(t.Sub(start).Truncate(rc.Duration))
+}
+
+// nextPeriod returns the start of the rotation period after the one
+// holding t. Local days may be 23 or 25 hours long across DST changes.
+func (rc *RotateConfig) nextPeriod(t time.Time) time.Time {
+	if !rc.localAligned() {
+		return t.Add(rc.Duration).Truncate(rc.Duration)
+	}
+	next, tomorrow := rc.periodStart(t).Add(rc.Duration), midnight(t, 1)
+	if rc.Duration == day || next.After(tomorrow) {
+		return tomorrow
+	}
+	return next
+}
@@ -0,0 +1,73 @@
+package rotatelog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotateDailyLocalMidnight(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 10, 16, 15, 30, 0, 0, shanghai)
+
+	rc := RotateDaily(7)
+	if got, want := rc.periodStart(now), time.Date(2026, 10, 16, 0, 0, 0, 0, shanghai); !got.Equal(want) {
+		t.Errorf("periodStart = %v, want %v", got, want)
+	}
+	if got, want := rc.nextPeriod(now), time.Date(2026, 10, 17, 0, 0, 0, 0, shanghai); !got.Equal(want) {
+		t.Errorf("nextPeriod = %v, want %v", got, want)
+	}
+
+	// plain Duration keeps aligning to UTC midnight, 08:00 here
+	plain := &RotateConfig{MaxBackups: 7, Duration: 24 * time.Hour}
+	if got, want := plain.nextPeriod(now), time.Date(2026, 10, 17, 8, 0, 0, 0, shanghai); !got.Equal(want) {
+		t.Errorf("plain nextPeriod = %v, want %v", got, want)
+	}
+
+	hourly := RotateHourly(24)
+	half := time.FixedZone("IST", 5*3600+1800)
+	at := time.Date(2026, 10, 16, 15, 10, 0, 0, half)
+	if got, want := hourly.nextPeriod(at), time.Date(2026, 10, 16, 16, 0, 0, 0, half); !got.Equal(want) {
+		t.Errorf("hourly nextPeriod = %v, want %v", got, want)
+	}
+}
+
+func TestRotateDailyDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+
+	// 2026-11-01 is 25 hours long in New York
+	rc := RotateDaily(7)
+	now := time.Date(2026, 11, 1, 23, 30, 0, 0, ny)
+	if got, want := rc.periodStart(now), time.Date(2026, 11, 1, 0, 0, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("periodStart = %v, want %v", got, want)
+	}
+	if got, want := rc.nextPeriod(now), time.Date(2026, 11, 2, 0, 0, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("nextPeriod = %v, want %v", got, want)
+	}
+}
+
+func TestSuffixTimeFunc(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// yesterday, so the archive is within retention
+	businessDay := midnight(time.Now(), -1).Add(10 * time.Hour)
+	rc := RotateDaily(5)
+	rc.SuffixTimeFunc = func() time.Time { return businessDay }
+	logger := New(f, "", 0, LevelDebug, rc)
+	logger.Info("closing the books")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+
+	if _, err = os.Stat(logFile + "." + midnight(businessDay, 0).Format(formatMin)); err != nil {
+		t.Errorf("archive not named after the business day: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package rotatelog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Sharded spreads records over several Loggers, each writing and rotating
+// its own file, so readers can consume them in parallel.
+type Sharded struct {
+	next   uint32
+	shards []*Logger
+}
+
+// NewSharded opens n files named after path with the shard index before the
+// extension, app.log giving app.0.log, app.1.log and so on. Each gets its
+// own Logger with the given settings, rotation is started when rc is set.
+func NewSharded(path string, n int, prefix string, flag int, level Level, rc *RotateConfig) (s *Sharded, err error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid shard count %d", n)
+	}
+	var (
+		ext  = filepath.Ext(path)
+		base = strings.TrimSuffix(path, ext)
+	)
+	s = &Sharded{}
+	for i := 0; i < n; i++ {
+		var l *Logger
+		l, err = NewFile(fmt.Sprintf("%s.%d%s", base, i, ext), prefix, flag, level, rc)
+		if nil != err {
+			s.Close()
+			return nil, err
+		}
+		s.shards = append(s.shards, l)
+		if rc != nil {
+			if _, err = l.StartRotate(); nil != err {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// Shards returns the shard Loggers in index order.
+func (s *Sharded) Shards() []*Logger {
+	return s.shards
+}
+
+// Next returns the shards in turn.
+func (s *Sharded) Next() *Logger {
+	i := atomic.AddUint32(&s.next, 1) - 1
+	return s.shards[i%uint32(len(s.shards))]
+}
+
+// Shard returns the shard of key, the same one for the same key so related
+// records stay in order.
+func (s *Sharded) Shard(key string) *Logger {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// Log writes a record to the next shard in turn.
+func (s *Sharded) Log(level Level, format string, v ...interface{}) {
+	l := s.Next()
+	if !l.enabled(level) {
+		return
+	}
+	l.output(3, level, fmt.Sprintf(format, v...))
+}
+
+// Rotate rotates every shard.
+func (s *Sharded) Rotate() (err error) {
+	for _, l := range s.shards {
+		if rerr := l.Rotate(); nil != rerr {
+			err = rerr
+		}
+	}
+	return
+}
+
+// Close stops rotation, waits for pending archives and closes the files.
+func (s *Sharded) Close() (err error) {
+	for _, l := range s.shards {
+		if cerr := l.Close(); nil != cerr {
+			err = cerr
+		}
+	}
+	return
+}
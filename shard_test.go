@@ -0,0 +1,36 @@
+package rotatelog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSharded(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSharded(filepath.Join(dir, "app.log"), 4, "", 0, LevelInfo, &RotateConfig{Duration: time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.Log(LevelInfo, "record %d", i)
+	}
+	if s.Shard("user-42") != s.Shard("user-42") {
+		t.Error("Shard should be stable for a key")
+	}
+	s.Close()
+
+	for i := 0; i < 4; i++ {
+		b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("app.%d.log", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := bytes.Count(b, []byte("\n")); n != 250 {
+			t.Errorf("shard %d has %d records, want 250", i, n)
+		}
+	}
+}
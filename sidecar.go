@@ -0,0 +1,129 @@
+package rotatelog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// sidecar is a file rotated together with the log file.
+type sidecar struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (s *sidecar) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return 0, os.ErrClosed
+	}
+	return s.f.Write(p)
+}
+
+// rotate renames the sidecar to renameTo and reopens it. Where an open
+// file can't be renamed, as on Windows, it is closed for the rename,
+// writes waiting meanwhile.
+func (s *sidecar) rotate(l *Logger, renameTo string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := renameFile(s.path, renameTo)
+	if nil != err && renameBusy(err) && s.f != nil {
+		s.f.Close()
+		s.f = nil
+		err = renameFile(s.path, renameTo)
+	}
+	if nil == err {
+		var f *os.File
+		if f, err = l.openFile(s.path); nil != err {
+			renameFile(renameTo, s.path)
+		} else {
+			if s.f != nil {
+				s.f.Close()
+			}
+			s.f = f
+		}
+	}
+	if s.f == nil {
+		// closed for a failed rename, go on with the old file
+		if f, oerr := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); nil == oerr {
+			s.f = f
+		}
+	}
+	return err
+}
+
+// close closes the sidecar file, later writes fail.
+func (s *sidecar) close() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f != nil {
+		err = s.f.Close()
+		s.f = nil
+	}
+	return
+}
+
+// AddSidecar opens path for appending and returns a writer for it. Each
+// Rotate renames it with the same suffix as the log file and reopens it,
+// so paired files such as an index stay matched. Its archives are
+// compressed, encrypted and cleaned like the log file's. Close closes it.
+func (l *Logger) AddSidecar(path string) (io.Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if nil != err {
+		return nil, err
+	}
+	s := &sidecar{path: path, f: f}
+
+	l.mu.Lock()
+	l.sidecars = append(l.sidecars, s)
+	l.mu.Unlock()
+	return s, nil
+}
+
+// rotateSidecars renames the sidecars to path.suffix plus fragment and
+// reopens them, returning the archive names without the fragment.
+func (l *Logger) rotateSidecars(suffix, fragment string) (targets []string) {
+	l.mu.Lock()
+	sidecars := l.sidecars
+	l.mu.Unlock()
+
+	for _, s := range sidecars {
+		var (
+			target   = fmt.Sprintf("%s.%s", s.path, suffix)
+			renameTo = l.rotateCfg.stage(target + fragment)
+		)
+		if err := s.rotate(l, renameTo); nil != err {
+			l.Error("rotate sidecar %s fail: %s", s.path, err.Error())
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return
+}
+
+// closeSidecars closes the sidecar files.
+func (l *Logger) closeSidecars() (err error) {
+	l.mu.Lock()
+	sidecars := l.sidecars
+	l.mu.Unlock()
+	for _, s := range sidecars {
+		if cerr := s.close(); nil == err {
+			err = cerr
+		}
+	}
+	return
+}
+
+// sidecarPaths returns the paths of the sidecars.
+func (l *Logger) sidecarPaths() (paths []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range l.sidecars {
+		paths = append(paths, s.path)
+	}
+	return
+}
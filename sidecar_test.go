@@ -0,0 +1,97 @@
+package rotatelog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSidecar(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	f, err := os.OpenFile(logFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := New(f, "", 0, LevelDebug, &RotateConfig{Duration: time.Minute, MaxBackups: 5})
+	idx, err := logger.AddSidecar(filepath.Join(dir, "app.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("record")
+	idx.Write([]byte("offset 0\n"))
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	logger.Info("next")
+	idx.Write([]byte("offset 1\n"))
+
+	logs, _ := filepath.Glob(logFile + ".*")
+	idxs, _ := filepath.Glob(filepath.Join(dir, "app.idx.*"))
+	if len(logs) != 1 || len(idxs) != 1 {
+		t.Fatalf("want one archive each, got %v and %v", logs, idxs)
+	}
+	if a, b := strings.TrimPrefix(logs[0], logFile), strings.TrimPrefix(idxs[0], filepath.Join(dir, "app.idx")); a != b {
+		t.Errorf("suffixes differ: %q and %q", a, b)
+	}
+	if b, _ := ioutil.ReadFile(idxs[0]); string(b) != "offset 0\n" {
+		t.Errorf("archived sidecar = %q", b)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "app.idx")); string(b) != "offset 1\n" {
+		t.Errorf("reopened sidecar = %q", b)
+	}
+}
+
+func TestSidecarRenameBusy(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Minute, MaxBackups: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := logger.AddSidecar(filepath.Join(dir, "app.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := w.(*sidecar)
+
+	// like Windows, refuse to rename the sidecar while it is open
+	errBusy := errors.New("sharing violation")
+	renameFile = func(from, to string) error {
+		if from == idx.path && idx.f != nil {
+			return errBusy
+		}
+		return os.Rename(from, to)
+	}
+	renameBusy = func(err error) bool { return err == errBusy }
+	defer func() { renameFile, renameBusy = os.Rename, isRenameBusy }()
+
+	idx.Write([]byte("offset 0\n"))
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	idx.Write([]byte("offset 1\n"))
+	if err = logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = idx.Write([]byte("closed\n")); err == nil {
+		t.Error("sidecar written after Close")
+	}
+
+	idxs, _ := filepath.Glob(filepath.Join(dir, "app.idx.*"))
+	if len(idxs) != 1 {
+		t.Fatalf("sidecar archives %v", idxs)
+	}
+	if b, _ := ioutil.ReadFile(idxs[0]); string(b) != "offset 0\n" {
+		t.Errorf("archived sidecar = %q", b)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "app.idx")); string(b) != "offset 1\n" {
+		t.Errorf("reopened sidecar = %q", b)
+	}
+}
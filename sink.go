@@ -0,0 +1,38 @@
+package rotatelog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ship copies the archive at path to the ArchiveSink and removes it unless
+// KeepShipped is set. On failure the archive stays in place.
+func (l *Logger) ship(path string) (err error) {
+	f, err := os.Open(path)
+	if nil != err {
+		l.Error("open archive for sink err:%s", err.Error())
+		return
+	}
+	defer f.Close()
+
+	w, err := l.archiveSink(filepath.Base(path))
+	if nil != err {
+		l.Error("archive sink for %s err:%s", path, err.Error())
+		return
+	}
+	_, err = io.Copy(w, f)
+	if cerr := w.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		l.Error("ship archive %s err:%s, kept", path, err.Error())
+		return
+	}
+
+	if !l.rotateCfg.KeepShipped {
+		f.Close()
+		err = os.Remove(path)
+	}
+	return
+}
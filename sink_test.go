@@ -0,0 +1,72 @@
+package rotatelog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sinkBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *sinkBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestArchiveSink(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	var (
+		names []string
+		sink  sinkBuffer
+	)
+	rc := &RotateConfig{Duration: time.Hour, MaxBackups: 2, ArchiveSink: func(name string) (io.WriteCloser, error) {
+		names = append(names, name)
+		return &sink, nil
+	}}
+	logger, err := NewFile(logFile, "", 0, LevelDebug, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	logger.Info("shipped")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	if len(names) != 1 || !strings.HasPrefix(names[0], "app.log.") || !sink.closed {
+		t.Fatalf("sink got %q, closed %v", names, sink.closed)
+	}
+	if sink.String() != "[Info] shipped\n" {
+		t.Errorf("sink got %q", sink.String())
+	}
+	if _, err = os.Stat(filepath.Join(filepath.Dir(logFile), names[0])); !os.IsNotExist(err) {
+		t.Errorf("shipped archive kept: %v", err)
+	}
+
+	// a failing sink keeps the local archive
+	rc.ArchiveSink = func(name string) (io.WriteCloser, error) {
+		return nil, errors.New("unreachable")
+	}
+	logger.Info("kept")
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.WaitPending()
+	archives, _ := filepath.Glob(logFile + ".*")
+	if len(archives) != 1 {
+		t.Fatalf("want the local archive, got %q", archives)
+	}
+	if data, _ := ioutil.ReadFile(archives[0]); string(data) != "[Info] kept\n" {
+		t.Errorf("archive holds %q", data)
+	}
+}
@@ -0,0 +1,63 @@
+package rotatelog
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// defaultStackDepth limits the stack of ErrorStack without StackDepth.
+const defaultStackDepth = 32
+
+// ErrorStack logs err at LevelError after the message with a stack trace.
+// An error formatting its own stack with %+v, as github.com/pkg/errors
+// does, is printed that way, otherwise the caller's stack is captured.
+func (l *Logger) ErrorStack(err error, format string, v ...interface{}) {
+	if !l.enabled(LevelError) {
+		return
+	}
+	s := fmt.Sprintf(format, v...)
+	if f, ok := err.(fmt.Formatter); ok {
+		s += ": " + fmt.Sprintf("%+v", f)
+	} else {
+		depth := l.StackDepth
+		if depth <= 0 {
+			depth = defaultStackDepth
+		}
+		s += fmt.Sprintf(": %v", err) + stack(0, depth)
+	}
+	l.output(3, LevelError, s)
+}
+
+// stack renders up to depth frames of the calling goroutine, skip counts
+// the frames above stack's caller to leave out.
+func stack(skip, depth int) string {
+	pcs := make([]uintptr, depth)
+	n := runtime.Callers(skip+3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "\n\t%s\n\t\t%s:%d", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// goroutineID parses the ID of the calling goroutine out of the
+// "goroutine N [running]:" header of its stack, 0 if that fails.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,64 @@
+package rotatelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStackLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.StackLevel, logger.StackDepth = LevelCritical, 2
+
+	logger.Info("plain")
+	if buf.String() != "[Info] plain\n" {
+		t.Errorf("Info got a stack: %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.Critical("bad")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if lines[0] != "[Critical] bad" || len(lines) != 1+2*2 {
+		t.Fatalf("want the record and 2 frames, got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[1], ".TestStackLevel") || !strings.Contains(lines[2], "stack_test.go:") {
+		t.Errorf("stack should start at the caller: %q", lines[1:3])
+	}
+
+	buf.Reset()
+	logger.ErrorStack(errors.New("boom"), "query %d", 7)
+	if out := buf.String(); !strings.HasPrefix(out, "[Error] query 7: boom\n\t") || !strings.Contains(out, ".TestStackLevel\n") {
+		t.Errorf("ErrorStack got %q", out)
+	}
+}
+
+func TestIncludeGID(t *testing.T) {
+	var buf syncBuffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.IncludeGID, logger.IncludeSeq = true, true
+
+	done := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func() {
+			logger.Info("hello")
+			done <- true
+		}()
+	}
+	<-done
+	<-done
+
+	gids := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var seq, gid int
+		if n, _ := fmt.Sscanf(line, "[Info] seq=%d gid=%d hello", &seq, &gid); n != 2 || gid == 0 {
+			t.Fatalf("unexpected line %q", line)
+		}
+		gids[fmt.Sprint(gid)] = true
+	}
+	if len(gids) != 2 {
+		t.Errorf("want two distinct gids, got %q", buf.String())
+	}
+}
@@ -0,0 +1,73 @@
+package rotatelog
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts the records a Logger dropped, by cause, and the bytes of
+// the archives it compressed, whose ratio tells how well logs compress.
+type Stats struct {
+	Filtered uint64 // rejected by the Filter
+	DiskFull uint64 // failed or skipped while the disk was full
+	Sampled  uint64 // thinned out by Sampling
+
+	PlainBytes      uint64 // archives compressed by Compress or a Pipeline, before
+	CompressedBytes uint64 // and after, as the final files
+}
+
+// Stats returns the counters since the Logger was created, a WithLevel
+// child counts along with its parent.
+func (l *Logger) Stats() Stats {
+	l = l.base()
+	return Stats{
+		Filtered: atomic.LoadUint64(&l.dropFiltered),
+		DiskFull: atomic.LoadUint64(&l.dropDiskFull),
+		Sampled:  atomic.LoadUint64(&l.dropSampled),
+
+		PlainBytes:      atomic.LoadUint64(&l.plainBytes),
+		CompressedBytes: atomic.LoadUint64(&l.packedBytes),
+	}
+}
+
+// countCompressed adds an archive compressed from plain to packed bytes.
+func (l *Logger) countCompressed(plain, packed int64) {
+	l = l.base()
+	atomic.AddUint64(&l.plainBytes, uint64(plain))
+	atomic.AddUint64(&l.packedBytes, uint64(packed))
+}
+
+// fileSize returns the size of path, 0 if it can't be read.
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if nil != err {
+		return 0
+	}
+	return fi.Size()
+}
+
+// Reset clears the counters and the write error state: Stats, the
+// IncludeSeq sequence, the Sampling counts and the disk full and Fallback
+// modes, and flushes a buffering output. Output, level and settings are
+// kept. It is for tests and pooled Loggers and must only be called while
+// nothing logs.
+func (l *Logger) Reset() {
+	l = l.base()
+	l.flush()
+	atomic.StoreUint64(&l.seq, 0)
+	atomic.StoreUint64(&l.dropFiltered, 0)
+	atomic.StoreUint64(&l.dropDiskFull, 0)
+	atomic.StoreUint64(&l.dropSampled, 0)
+	atomic.StoreUint64(&l.plainBytes, 0)
+	atomic.StoreUint64(&l.packedBytes, 0)
+	*l.samples = [LevelCritical + 1]sampleCounter{}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastUsageWarn = time.Time{}
+	l.fullSince, l.fullRetry, l.dropped = time.Time{}, time.Time{}, 0
+	l.fullErr, l.fullNotice = nil, ""
+	l.failures, l.fallbackSince, l.primaryRetry = 0, time.Time{}, time.Time{}
+	l.fallbackNotice = ""
+}
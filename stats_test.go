@@ -0,0 +1,84 @@
+package rotatelog
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	defer func(d time.Duration) { diskFullRetry = d }(diskFullRetry)
+	diskFullRetry = time.Hour
+
+	disk := &fullDisk{name: "app.log"}
+	logger := New(disk, "", 0, LevelDebug, nil)
+	logger.ErrorHandler = func(err error) {}
+	logger.SetFilter(func(level Level, msg string) bool { return !strings.HasPrefix(msg, "noise") })
+
+	logger.Info("noise 1")
+	logger.WithLevel(LevelDebug).Debug("noise 2")
+	logger.WriteBatch(LevelInfo, []string{"kept", "noise 3"})
+	disk.full = true
+	logger.Info("lost")
+	logger.Info("skipped")
+
+	if got, want := logger.Stats(), (Stats{Filtered: 3, DiskFull: 2}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	defer func(d time.Duration) { diskFullRetry = d }(diskFullRetry)
+	diskFullRetry = time.Hour
+
+	disk := &fullDisk{name: "app.log", full: true}
+	logger := New(disk, "", 0, LevelDebug, nil)
+	logger.IncludeSeq = true
+	logger.ErrorHandler = func(err error) {}
+	logger.SetFilter(func(level Level, msg string) bool { return msg != "noise" })
+	logger.Info("noise")
+	logger.Info("lost")
+	logger.Info("dropped")
+	if logger.Stats() == (Stats{}) {
+		t.Fatal("nothing counted")
+	}
+
+	disk.full = false
+	logger.Reset()
+	if got := logger.Stats(); got != (Stats{}) {
+		t.Errorf("Stats() = %+v after Reset", got)
+	}
+	logger.Info("fresh")
+	if got := disk.buf.String(); got != "[Info] seq=1 fresh\n" {
+		t.Errorf("got %q after Reset", got)
+	}
+}
+
+func TestStatsCompression(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewFile(logFile, "", 0, LevelDebug, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, SyncMaintenance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	for i := 0; i < 100; i++ {
+		logger.Info("the same request served in %d ms", 12)
+	}
+	plain := fileSize(logFile)
+	if err = logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	archives, _ := logger.Archives()
+	if len(archives) != 1 || !archives[0].Compressed {
+		t.Fatalf("archives %+v", archives)
+	}
+	st := logger.Stats()
+	if st.PlainBytes != uint64(plain) || st.CompressedBytes != uint64(archives[0].Size) {
+		t.Errorf("Stats() = %+v, want %d plain and %d compressed bytes", st, plain, archives[0].Size)
+	}
+	if st.CompressedBytes == 0 || st.CompressedBytes >= st.PlainBytes {
+		t.Errorf("compressed %d of %d bytes", st.CompressedBytes, st.PlainBytes)
+	}
+}
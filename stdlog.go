@@ -0,0 +1,111 @@
+package rotatelog
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Output overrides log.Logger.Output so callers of it go through level
+// filtering, at LevelError since that is what stdlib users log there.
+func (l *Logger) Output(calldepth int, s string) error {
+	if !l.enabled(LevelError) {
+		return nil
+	}
+	return l.output(calldepth+2, LevelError, s)
+}
+
+// OutputLevel writes s at level unless level is filtered, calldepth counts
+// frames as in log.Logger.Output. It is meant for adapters such as logrsink.
+func (l *Logger) OutputLevel(calldepth int, level Level, s string) error {
+	if !l.enabled(level) {
+		return nil
+	}
+	return l.output(calldepth+2, level, s)
+}
+
+// StdLogger returns a *log.Logger writing through l at level, for APIs such
+// as http.Server.ErrorLog that only accept the stdlib type.
+func (l *Logger) StdLogger(level Level) *log.Logger {
+	return log.New(stdWriter{l, level}, "", 0)
+}
+
+type stdWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	if w.l.enabled(w.level) {
+		// skip stdWriter.Write, log.Logger.output and its exported caller
+		w.l.output(5, w.level, strings.TrimSuffix(string(p), "\n"))
+	}
+	return len(p), nil
+}
+
+// Print logs at LevelInfo like Printf, arguments are handled as fmt.Print.
+func (l *Logger) Print(v ...interface{}) {
+	l.log(LevelInfo, "%s", fmt.Sprint(v...))
+}
+
+// Println logs at LevelInfo, arguments are handled as fmt.Println.
+func (l *Logger) Println(v ...interface{}) {
+	l.log(LevelInfo, "%s", fmt.Sprintln(v...))
+}
+
+// exitCloser is an output whose data is only complete once closed, such as
+// the OpenGzip and OpenMmap files.
+type exitCloser interface {
+	io.Closer
+	closeBeforeExit()
+}
+
+// exit syncs the output, closing an exitCloser, and calls os.Exit(1).
+func (l *Logger) exit() {
+	l.Sync()
+	if c, ok := l.Writer().(exitCloser); ok {
+		c.Close()
+	}
+	os.Exit(1)
+}
+
+// Fatal logs at LevelCritical, flushes the output and calls os.Exit(1).
+func (l *Logger) Fatal(v ...interface{}) {
+	l.log(LevelCritical, "%s", fmt.Sprint(v...))
+	l.exit()
+}
+
+// Fatalf logs at LevelCritical, flushes the output and calls os.Exit(1).
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.log(LevelCritical, format, v...)
+	l.exit()
+}
+
+// Fatalln logs at LevelCritical, flushes the output and calls os.Exit(1).
+func (l *Logger) Fatalln(v ...interface{}) {
+	l.log(LevelCritical, "%s", fmt.Sprintln(v...))
+	l.exit()
+}
+
+// Panic logs at LevelCritical then panics with the message.
+func (l *Logger) Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	l.log(LevelCritical, "%s", s)
+	panic(s)
+}
+
+// Panicf logs at LevelCritical then panics with the message.
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	l.log(LevelCritical, "%s", s)
+	panic(s)
+}
+
+// Panicln logs at LevelCritical then panics with the message.
+func (l *Logger) Panicln(v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	l.log(LevelCritical, "%s", s)
+	panic(s)
+}
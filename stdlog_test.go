@@ -0,0 +1,180 @@
+package rotatelog
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestOutputLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "", 0, LevelDebug, nil)
+	logger.Output(1, "raw output")
+	if buf.String() != "[Error] raw output\n" {
+		t.Errorf("got %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.SetLevel(LevelCritical)
+	logger.Output(1, "raw output")
+	if buf.Len() != 0 {
+		t.Errorf("Output not gated by level: %q", buf.String())
+	}
+}
+
+func TestStdLoggerErrorLog(t *testing.T) {
+	var buf syncBuffer
+	logger := New(&buf, "", 0, LevelInfo, nil)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	ts.Config.ErrorLog = logger.StdLogger(LevelError)
+	ts.Start()
+	defer ts.Close()
+
+	if resp, err := http.Get(ts.URL); err == nil {
+		resp.Body.Close()
+	}
+	ts.Close()
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "[Error] http: panic serving") || !strings.Contains(out, "boom") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+// stdLogger is the method set of *log.Logger.
+type stdLogger interface {
+	Output(calldepth int, s string) error
+	Print(v ...interface{})
+	Printf(format string, v ...interface{})
+	Println(v ...interface{})
+	Fatal(v ...interface{})
+	Fatalf(format string, v ...interface{})
+	Fatalln(v ...interface{})
+	Panic(v ...interface{})
+	Panicf(format string, v ...interface{})
+	Panicln(v ...interface{})
+	Flags() int
+	SetFlags(flag int)
+	Prefix() string
+	SetPrefix(prefix string)
+	SetOutput(w io.Writer)
+	Writer() io.Writer
+}
+
+var (
+	_ stdLogger = (*log.Logger)(nil)
+	_ stdLogger = (*Logger)(nil)
+)
+
+func TestStdLoggerMethods(t *testing.T) {
+	var buf bytes.Buffer
+	var l stdLogger = New(ioutil.Discard, "", 0, LevelDebug, nil)
+
+	l.SetOutput(&buf)
+	if l.Writer() != &buf {
+		t.Errorf("Writer() = %v", l.Writer())
+	}
+	l.SetFlags(log.Lmsgprefix)
+	l.SetPrefix("app: ")
+	if l.Flags() != log.Lmsgprefix || l.Prefix() != "app: " {
+		t.Errorf("flags %d prefix %q", l.Flags(), l.Prefix())
+	}
+
+	l.Print("print ", 1)
+	l.Printf("printf %d", 2)
+	l.Println("println", 3)
+	l.Output(1, "output")
+	for _, panics := range []func(){
+		func() { l.Panic("panic ", 4) },
+		func() { l.Panicf("panicf %d", 5) },
+		func() { l.Panicln("panicln", 6) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			panics()
+		}()
+	}
+
+	want := "app: [Info] print 1\n" +
+		"app: [Info] printf 2\n" +
+		"app: [Info] println 3\n" +
+		"app: [Error] output\n" +
+		"app: [Critical] panic 4\n" +
+		"app: [Critical] panicf 5\n" +
+		"app: [Critical] panicln 6\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestStdLoggerFatal(t *testing.T) {
+	if os.Getenv("ROTATELOG_FATAL") == "1" {
+		var l stdLogger = New(os.Stderr, "", 0, LevelDebug, nil)
+		l.Fatalf("fatal %d", 7)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdLoggerFatal$")
+	cmd.Env = append(os.Environ(), "ROTATELOG_FATAL=1")
+	out, err := cmd.CombinedOutput()
+	if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 1 {
+		t.Fatalf("want exit status 1, got %v", err)
+	}
+	if !strings.Contains(string(out), "[Critical] fatal 7\n") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestFatalClosesLiveGzip(t *testing.T) {
+	if path := os.Getenv("ROTATELOG_FATAL_GZIP"); path != "" {
+		w, err := OpenGzip(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		New(w, "", 0, LevelDebug, nil).Fatal("last words")
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log.gz")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalClosesLiveGzip$")
+	cmd.Env = append(os.Environ(), "ROTATELOG_FATAL_GZIP="+path)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("want exit status 1")
+	}
+	if got := readGzip(t, path); got != "[Critical] last words\n" {
+		t.Errorf("got %q", got)
+	}
+}
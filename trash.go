@@ -0,0 +1,59 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trashPath is the trash directory for archives of dir.
+func (rc *RotateConfig) trashPath(dir string) string {
+	if filepath.IsAbs(rc.TrashDir) {
+		return rc.TrashDir
+	}
+	return filepath.Join(dir, rc.TrashDir)
+}
+
+// trashFile moves fn to the trash unless BeforeDelete vetoes it, stamping
+// it with now to date its grace period, and returns its size.
+func (l *Logger) trashFile(fn string, now time.Time) (size int64, ok bool) {
+	if l.rotateCfg.BeforeDelete != nil && !l.beforeDelete(fn) {
+		return 0, false
+	}
+	fi, err := os.Stat(fn)
+	if nil != err {
+		return 0, false
+	}
+	trash := l.rotateCfg.trashPath(filepath.Dir(fn))
+	if err = os.MkdirAll(trash, 0755); nil != err {
+		l.Error("fail to create trash dir:%s, err:%s", trash, err.Error())
+		return 0, false
+	}
+	target := filepath.Join(trash, filepath.Base(fn))
+	if err = os.Rename(fn, target); nil != err {
+		l.Error("fail to move %s to trash, err:%s", fn, err.Error())
+		return 0, false
+	}
+	os.Chtimes(target, now, now)
+	return fi.Size(), true
+}
+
+// sweepTrash removes the files of the trash of dir moved there more than
+// TrashRetention (default a day) before now.
+func (l *Logger) sweepTrash(dir string, now time.Time) {
+	grace := l.rotateCfg.TrashRetention
+	if grace <= 0 {
+		grace = 24 * time.Hour
+	}
+	trash := l.rotateCfg.trashPath(dir)
+	files, err := ioutil.ReadDir(trash)
+	if nil != err {
+		return
+	}
+	for _, fi := range files {
+		if fi.Mode().IsRegular() && now.Sub(fi.ModTime()) > grace {
+			os.Remove(filepath.Join(trash, fi.Name()))
+		}
+	}
+}
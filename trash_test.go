@@ -0,0 +1,50 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrashDir(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	old := logFile + "." + base.Add(-48*time.Hour).Format(formatMin)
+	recent := logFile + "." + base.Format(formatMin)
+	for _, fn := range []string{old, recent} {
+		ioutil.WriteFile(fn, []byte("record\n"), 0644)
+	}
+
+	logger := New(ioutil.Discard, "", 0, LevelDebug, &RotateConfig{
+		Duration:       time.Hour,
+		MaxBackups:     24,
+		TrashDir:       ".trash",
+		TrashRetention: time.Hour,
+	})
+	if removed, _, err := logger.cleanOldLogs(base, logFile); removed != 1 || err != nil {
+		t.Fatalf("cleanOldLogs() = %d, %v", removed, err)
+	}
+	trashed := filepath.Join(dir, ".trash", filepath.Base(old))
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("%s still in place", old)
+	}
+	if b, err := ioutil.ReadFile(trashed); err != nil || string(b) != "record\n" {
+		t.Fatalf("trash has %q, %v", b, err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recent archive: %v", err)
+	}
+
+	// within the grace period the file stays in trash
+	logger.cleanOldLogs(base.Add(30*time.Minute), logFile)
+	if _, err := os.Stat(trashed); err != nil {
+		t.Errorf("trash swept early: %v", err)
+	}
+	logger.cleanOldLogs(base.Add(2*time.Hour), logFile)
+	if _, err := os.Stat(trashed); !os.IsNotExist(err) {
+		t.Errorf("%s kept past the grace period", trashed)
+	}
+}
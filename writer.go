@@ -0,0 +1,66 @@
+package rotatelog
+
+import "os"
+
+// Writer is an io.WriteCloser appending raw bytes to a rotated file,
+// without levels or formatting.
+type Writer struct {
+	l *Logger
+}
+
+// NewWriter opens path as NewFile does and, when rc is not nil, starts
+// rotating it. With rc.MkdirAll the missing directories are created.
+func NewWriter(path string, rc *RotateConfig) (*Writer, error) {
+	l, err := NewFile(path, "", 0, LevelDebug, rc)
+	if nil != err {
+		return nil, err
+	}
+
+	w := &Writer{l: l}
+	if rc != nil {
+		if _, err = w.l.StartRotate(); nil != err {
+			l.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Write appends p as is. While the disk is full writes fail and are only
+// retried every second, the Logger's ErrorHandler is told once.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	n, err = w.l.writeRaw(p)
+	w.l.report(false)
+	return
+}
+
+// Sync commits the current file to stable storage.
+func (w *Writer) Sync() error {
+	return w.l.Sync()
+}
+
+// Rotate rotates the file at once, see Logger.Rotate.
+func (w *Writer) Rotate() error {
+	return w.l.Rotate()
+}
+
+// Close stops rotation, waits for the pending archives and closes the
+// current file, see Logger.Close.
+func (w *Writer) Close() error {
+	return w.l.Close()
+}
+
+// openAppend opens path for appending, creating the missing directories
+// when rc.MkdirAll is set and reserving rc.Preallocate bytes.
+func openAppend(path string, rc *RotateConfig) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if nil != err && rc != nil && rc.MkdirAll && os.IsNotExist(err) {
+		if err = rc.mkdir(path); nil == err {
+			f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		}
+	}
+	if nil == err {
+		err = rc.preallocate(f)
+	}
+	return f, err
+}
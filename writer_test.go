@@ -0,0 +1,123 @@
+package rotatelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type writeSyncer interface {
+	Write(p []byte) (int, error)
+	Sync() error
+}
+
+var _ writeSyncer = (*Writer)(nil)
+
+func TestWriterSync(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "raw.log")
+	w, err := NewWriter(logFile, &RotateConfig{Duration: time.Hour, MaxBackups: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Write([]byte("raw line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Sync(); err != nil {
+		t.Errorf("Writer.Sync: %v", err)
+	}
+	if err = w.l.Sync(); err != nil {
+		t.Errorf("Logger.Sync: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(logFile); string(data) != "raw line\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+func TestWriterMkdirAll(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	logFile := filepath.Join(dir, "raw.log")
+	w, err := NewWriter(logFile, &RotateConfig{Duration: time.Hour, MaxBackups: 5, MkdirAll: true, DirMode: 0700})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0700 {
+		t.Fatalf("dir not created with DirMode: %v %v", fi, err)
+	}
+
+	if err = os.RemoveAll(filepath.Dir(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Rotate(); err != nil {
+		t.Fatalf("Rotate after the directory was removed: %v", err)
+	}
+	w.l.WaitPending()
+	if _, err = w.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(logFile); err != nil || string(b) != "after\n" {
+		t.Errorf("log file = %q, %v", b, err)
+	}
+}
+
+func TestWriterLiveGzip(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "access.log.gz")
+	w, err := NewWriter(logFile, &RotateConfig{Duration: time.Hour, MaxBackups: 5, LiveGzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err = w.Write([]byte("line one\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.l.WaitPending()
+
+	archives, _ := w.l.Archives()
+	if len(archives) != 1 {
+		t.Fatalf("archives %+v", archives)
+	}
+	if got := readGzip(t, archives[0].Path); got != "line one\n" {
+		t.Errorf("first archive has %q", got)
+	}
+}
+
+func TestWriterCloseWaitsPending(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "raw.log")
+	w, err := NewWriter(logFile, &RotateConfig{Duration: time.Hour, MaxBackups: 5, Compress: true, CompressOnClose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("archived\n"))
+	if err = w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("last\n"))
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is left to compress once Close returns
+	files, _ := filepath.Glob(logFile + "*")
+	if len(files) != 2 {
+		t.Fatalf("files %v", files)
+	}
+	for _, fn := range files {
+		if filepath.Ext(fn) != ".gz" {
+			t.Errorf("%s left uncompressed", fn)
+		}
+	}
+	if got := readGzip(t, logFile+".gz"); got != "last\n" {
+		t.Errorf("closed file has %q", got)
+	}
+}